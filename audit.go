@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordAudit appends an entry to audit_log. Pass the transaction making
+// the change so the entry is only kept if the change commits. A uuid.Nil
+// actor (the bypass key) is stored as NULL.
+func recordAudit(ctx context.Context, db execer, actor uuid.UUID, action, targetType, targetID string, details any) error {
+	var detailsJSON []byte
+	if details != nil {
+		var err error
+		if detailsJSON, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, target_type, target_id, details)
+		VALUES ($1, $2, $3, $4, $5)`,
+		uuid.NullUUID{UUID: actor, Valid: actor != uuid.Nil}, action, targetType, targetID, nullableJSON(detailsJSON))
+	return err
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// bundleFormatVersion is bumped whenever the bundle layout changes;
+// migrateBundle upgrades older bundles before they are imported.
+const bundleFormatVersion = 1
+
+type ProjectBundle struct {
+	FormatVersion int        `json:"format_version"`
+	ExportedAt    time.Time  `json:"exported_at"`
+	Project       Project    `json:"project"`
+	Entities      []Entity   `json:"entities"`
+	TestCases     []TestCase `json:"test_cases"`
+}
+
+func migrateBundle(b *ProjectBundle) error {
+	switch {
+	case b.FormatVersion == 0:
+		return fmt.Errorf("format_version is required")
+	case b.FormatVersion > bundleFormatVersion:
+		return fmt.Errorf("bundle format %d is newer than supported format %d", b.FormatVersion, bundleFormatVersion)
+	}
+	return nil
+}
+
+func (s *Server) exportProject(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	bundle := ProjectBundle{
+		FormatVersion: bundleFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Entities:      []Entity{},
+		TestCases:     []TestCase{},
+	}
+
+	bundle.Project, err = scanProject(s.replica.QueryRowContext(r.Context(), `SELECT `+projectColumns+` FROM projects WHERE id = $1`, projectID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+entityColumns+` FROM entities WHERE project_id = $1 ORDER BY name`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		bundle.Entities = append(bundle.Entities, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tcRows, err := s.replica.QueryContext(r.Context(), `SELECT `+testCaseColumns+` FROM test_cases WHERE project_id = $1 ORDER BY name`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tcRows.Close()
+
+	for tcRows.Next() {
+		tc, err := s.scanTestCase(tcRows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		tc.AssignedTo = nil
+		bundle.TestCases = append(bundle.TestCases, tc)
+	}
+	if err := tcRows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="project-%s.json"`, projectID))
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// importProject recreates a bundle as a new project. Every ID is replaced so
+// a bundle can be imported next to the project it was exported from;
+// entity_id and depends_on references are remapped to the new IDs.
+func (s *Server) importProject(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var bundle ProjectBundle
+	if err := decodeBody(r, &bundle); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := migrateBundle(&bundle); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	ids := make(map[uuid.UUID]uuid.UUID, len(bundle.Entities)+len(bundle.TestCases))
+	bundle.Project.ID = uuid.New()
+	if bundle.Project.DescriptionFormat == "" {
+		bundle.Project.DescriptionFormat = defaultDescriptionFormat
+	}
+	if !isValidDescriptionFormat(bundle.Project.DescriptionFormat) {
+		writeError(w, validationError("project: description_format must be one of "+strings.Join(descriptionFormats, ", ")))
+		return
+	}
+	metadata, err := encodeMetadata(bundle.Project.Metadata)
+	if err != nil {
+		writeError(w, validationError("project: "+err.Error()))
+		return
+	}
+	for _, e := range bundle.Entities {
+		ids[e.ID] = uuid.New()
+	}
+	for _, tc := range bundle.TestCases {
+		ids[tc.ID] = uuid.New()
+	}
+
+	for i := range bundle.Entities {
+		e := &bundle.Entities[i]
+		e.ID = ids[e.ID]
+		e.ProjectID = bundle.Project.ID
+
+		if err := checkJSONDepth(e.JSONData, s.cfg.MaxJSONDepth); err != nil {
+			writeError(w, validationError(fmt.Sprintf("entities[%d]: %v", i, err)))
+			return
+		}
+		if e.DescriptionFormat == "" {
+			e.DescriptionFormat = defaultDescriptionFormat
+		}
+		if !isValidDescriptionFormat(e.DescriptionFormat) {
+			writeError(w, validationError(fmt.Sprintf("entities[%d]: description_format must be one of %s", i, strings.Join(descriptionFormats, ", "))))
+			return
+		}
+	}
+	for i := range bundle.TestCases {
+		tc := &bundle.TestCases[i]
+		tc.ID = ids[tc.ID]
+		tc.ProjectID = bundle.Project.ID
+		tc.AssignedTo = nil
+
+		entityID, ok := ids[tc.EntityID]
+		if !ok {
+			writeError(w, validationError(fmt.Sprintf("test_cases[%d]: entity %s is not part of the bundle", i, tc.EntityID)))
+			return
+		}
+		tc.EntityID = entityID
+
+		if err := checkJSONDepth(tc.JSONData, s.cfg.MaxJSONDepth); err != nil {
+			writeError(w, validationError(fmt.Sprintf("test_cases[%d]: %v", i, err)))
+			return
+		}
+
+		deps := make([]uuid.UUID, 0, len(tc.DependsOn))
+		for _, dep := range tc.DependsOn {
+			newDep, ok := ids[dep]
+			if !ok {
+				writeError(w, validationError(fmt.Sprintf("test_cases[%d]: dependency %s is not part of the bundle", i, dep)))
+				return
+			}
+			deps = append(deps, newDep)
+		}
+		tc.DependsOn = deps
+
+		if tc.Tags, err = normalizeTags(tc.Tags); err != nil {
+			writeError(w, validationError(fmt.Sprintf("test_cases[%d]: %v", i, err)))
+			return
+		}
+
+		if tc.TimeoutMs != nil && *tc.TimeoutMs <= 0 {
+			writeError(w, validationError(fmt.Sprintf("test_cases[%d]: timeout_ms must be positive", i)))
+			return
+		}
+
+		if tc.Priority == "" {
+			tc.Priority = defaultLevel
+		}
+		if tc.Severity == "" {
+			tc.Severity = defaultLevel
+		}
+		if tc.DescriptionFormat == "" {
+			tc.DescriptionFormat = defaultDescriptionFormat
+		}
+		if !isValidDescriptionFormat(tc.DescriptionFormat) {
+			writeError(w, validationError(fmt.Sprintf("test_cases[%d]: description_format must be one of %s", i, strings.Join(descriptionFormats, ", "))))
+			return
+		}
+	}
+
+	tx, err := begin(r.Context(), s.db, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO projects (`+projectColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		bundle.Project.ID, bundle.Project.Name, bundle.Project.Description, bundle.Project.DescriptionFormat,
+		bundle.Project.AllowDuplicateNames, bundle.Project.BatchNotifications, bundle.Project.IsArchived, metadata)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for _, e := range bundle.Entities {
+		_, err := tx.Exec(`INSERT INTO entities (`+entityColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+			e.ID, e.Name, e.Description, e.DescriptionFormat, e.ProjectID, e.JSONData)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+	}
+
+	for _, tc := range bundle.TestCases {
+		sealed, err := s.sealJSONData(tc.JSONData)
+		if err != nil {
+			writeError(w, validationError(err.Error()))
+			return
+		}
+		_, err = tx.Exec(`
+			INSERT INTO test_cases (id, name, description, description_format, json_data, entity_id, project_id, requirement_id, priority, severity, depends_on, tags, timeout_ms)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			tc.ID, tc.Name, tc.Description, tc.DescriptionFormat, sealed, tc.EntityID, tc.ProjectID, tc.RequirementID, tc.Priority, tc.Severity,
+			pq.Array(tc.DependsOn), pq.Array(tc.Tags), tc.TimeoutMs)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bundle)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies(" 10.0.0.0/8, 192.168.1.7 ,, ::ffff:172.16.0.1, fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "172.16.0.1/32", "fd00::/8"}
+	if len(proxies) != len(want) {
+		t.Fatalf("proxies = %v, want %v", proxies, want)
+	}
+	for i, p := range proxies {
+		if p.String() != want[i] {
+			t.Errorf("proxies[%d] = %s, want %s", i, p, want[i])
+		}
+	}
+
+	for _, spec := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.1, 300.0.0.1"} {
+		if _, err := parseTrustedProxies(spec); err == nil {
+			t.Errorf("parseTrustedProxies(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"direct client", "203.0.113.5:4000", nil, "", "203.0.113.5"},
+		{"untrusted peer ignores XFF", "203.0.113.5:4000", []string{"198.51.100.1"}, "", "203.0.113.5"},
+		{"untrusted peer ignores X-Real-IP", "203.0.113.5:4000", nil, "198.51.100.1", "203.0.113.5"},
+		{"trusted peer", "10.0.0.2:4000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"rightmost untrusted hop wins", "10.0.0.2:4000", []string{"198.51.100.1, 203.0.113.9"}, "", "203.0.113.9"},
+		{"spoofed entries on the left", "10.0.0.2:4000", []string{"1.2.3.4, 5.6.7.8, 198.51.100.1, 10.0.0.3"}, "", "198.51.100.1"},
+		{"trusted hops are skipped", "10.0.0.2:4000", []string{"198.51.100.1, 10.1.1.1, 10.2.2.2"}, "", "198.51.100.1"},
+		{"repeated headers", "10.0.0.2:4000", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		{"garbage stops the walk", "10.0.0.2:4000", []string{"198.51.100.1, evil, 10.0.0.3"}, "", "10.0.0.2"},
+		{"all hops trusted", "10.0.0.2:4000", []string{"10.0.0.3"}, "", "10.0.0.2"},
+		{"X-Real-IP fallback", "10.0.0.2:4000", nil, "198.51.100.1", "198.51.100.1"},
+		{"invalid X-Real-IP", "10.0.0.2:4000", nil, "evil", "10.0.0.2"},
+		{"IPv4-mapped trusted peer", "[::ffff:10.0.0.2]:4000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"IPv4-mapped trusted hop", "10.0.0.2:4000", []string{"198.51.100.1, ::ffff:10.0.0.3"}, "", "198.51.100.1"},
+		{"IPv4-mapped untrusted peer", "[::ffff:203.0.113.5]:4000", []string{"198.51.100.1"}, "", "::ffff:203.0.113.5"},
+		{"IPv6 trusted peer", "[2001:db8::1]:4000", []string{"2001:db8::99"}, "", "2001:db8::99"},
+		{"remote without port", "10.0.0.2", []string{"198.51.100.1"}, "", "198.51.100.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+
+			if got := proxies.clientIP(r); got != tc.want {
+				t.Errorf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Environment      string
+	Port             string
+	BasePath         string
+	MaxBatchSize     int
+	MaxRunCases      int
+	GzipMinSize      int
+	MaintenanceMode  bool
+	RetryAfter       int
+	PrettyJSON       bool
+	JSONDataKeys     string
+	MaxJSONDepth     int
+	NotifyURL        string
+	NotifySecret     string
+	RunsPage         PageLimits
+	TestCasesPage    PageLimits
+	RequirementsPage PageLimits
+	UsersPage        PageLimits
+	PasswordPolicy   PasswordPolicy
+	TrustedProxies   string
+	JWTSigningKeys   string
+	JWTTTL           string
+	BypassKey        string
+	BypassRole       string
+	RunIsolation     string
+	CaseTimeout      time.Duration
+
+	RequestTimeout     time.Duration
+	LongRequestTimeout time.Duration
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	RequirementsCacheTTL time.Duration
+
+	DB DBConfig
+}
+
+type DBConfig struct {
+	Host        string
+	Port        int
+	User        string
+	Password    string
+	Name        string
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	WarmupConns int
+	ReplicaDSN  string
+}
+
+func loadConfig() Config {
+	env := envString("APP_ENV", "development")
+	return Config{
+		Environment:      env,
+		Port:             envString("PORT", "8080"),
+		BasePath:         normalizeBasePath(os.Getenv("BASE_PATH")),
+		MaxBatchSize:     envInt("MAX_BATCH_SIZE", 1000),
+		MaxRunCases:      envInt("MAX_RUN_CASES", 1000),
+		GzipMinSize:      envInt("GZIP_MIN_SIZE", 1024),
+		MaintenanceMode:  envBool("MAINTENANCE_MODE", false),
+		RetryAfter:       envInt("MAINTENANCE_RETRY_AFTER", 300),
+		PrettyJSON:       envBool("PRETTY_JSON", false),
+		JSONDataKeys:     os.Getenv("JSONDATA_ENCRYPTION_KEYS"),
+		MaxJSONDepth:     envInt("MAX_JSON_DEPTH", 32),
+		NotifyURL:        os.Getenv("NOTIFY_URL"),
+		NotifySecret:     os.Getenv("NOTIFY_SECRET"),
+		RunsPage:         envPageLimits("RUNS", defaultPageLimits),
+		TestCasesPage:    envPageLimits("TESTCASES", defaultPageLimits),
+		RequirementsPage: envPageLimits("REQUIREMENTS", defaultPageLimits),
+		UsersPage:        envPageLimits("USERS", defaultPageLimits),
+		PasswordPolicy:   envPasswordPolicy(defaultPasswordPolicy),
+		TrustedProxies:   os.Getenv("TRUSTED_PROXIES"),
+		JWTSigningKeys:   os.Getenv("JWT_SIGNING_KEYS"),
+		JWTTTL:           os.Getenv("JWT_TTL"),
+		BypassKey:        os.Getenv("BYPASS_KEY"),
+		BypassRole:       os.Getenv("BYPASS_ROLE"),
+		RunIsolation:     os.Getenv("RUN_ISOLATION_LEVEL"),
+		CaseTimeout:      envDuration("RUN_CASE_TIMEOUT", 5*time.Minute),
+
+		RequestTimeout:     envDuration("REQUEST_TIMEOUT", 30*time.Second),
+		LongRequestTimeout: envDuration("LONG_REQUEST_TIMEOUT", 10*time.Minute),
+
+		ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+
+		RequirementsCacheTTL: envDuration("REQUIREMENTS_CACHE_TTL", 5*time.Minute),
+
+		DB: loadDBConfig(env == "production"),
+	}
+}
+
+// loadDBConfig reads the DB_SSL* settings, DB_WARMUP_CONNS and
+// DB_REPLICA_DSN. SSL defaults
+// to require in production (APP_ENV=production) and to disable otherwise.
+func loadDBConfig(production bool) DBConfig {
+	sslMode := "disable"
+	if production {
+		sslMode = "require"
+	}
+	return DBConfig{
+		Host:        dbHost,
+		Port:        dbPort,
+		User:        dbUser,
+		Password:    dbPassword,
+		Name:        dbName,
+		SSLMode:     envString("DB_SSLMODE", sslMode),
+		SSLRootCert: os.Getenv("DB_SSLROOTCERT"),
+		SSLCert:     os.Getenv("DB_SSLCERT"),
+		SSLKey:      os.Getenv("DB_SSLKEY"),
+		WarmupConns: envInt("DB_WARMUP_CONNS", 0),
+		ReplicaDSN:  os.Getenv("DB_REPLICA_DSN"),
+	}
+}
+
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("invalid integer env value, using default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Warn("invalid boolean env value, using default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return b
+}
+
+const defaultJWTTTL = 4 * time.Hour
+
+// parseJWTTTL reads JWT_TTL. Unlike the other durations a bad value is
+// fatal: silently falling back would hand out tokens with a lifetime
+// nobody asked for.
+func parseJWTTTL(v string) (time.Duration, error) {
+	if v == "" {
+		return defaultJWTTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", d)
+	}
+	return d, nil
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		slog.Warn("invalid duration env value, using default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return d
+}
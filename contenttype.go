@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+var errEmptyBody = errors.New("request body is required")
+
+func decodeBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return errEmptyBody
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return errEmptyBody
+	}
+	return err
+}
+
+// decodeOptionalBody is decodeBody for endpoints where the body may be
+// omitted; an empty body leaves v untouched.
+func decodeOptionalBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return nil
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// rawBodyPaths take a body that is not JSON, such as an uploaded file.
+var rawBodyPaths = map[string]bool{
+	"/testcases/import/junit": true,
+}
+
+func requireJSONBody(next http.Handler, basePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength == 0 || rawBodyPaths[routePath(r.URL.Path, basePath)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
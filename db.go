@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Database is the subset of *sql.DB the server uses. Every statement takes
+// a context: handlers pass r.Context(), so a request that times out or is
+// abandoned cancels its queries.
+type Database interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	Conn(ctx context.Context) (*sql.Conn, error)
+	PingContext(ctx context.Context) error
+	Close() error
+}
+
+type DB struct {
+	*sql.DB
+	logger *slog.Logger
+}
+
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+func (c DBConfig) connString() (string, error) {
+	if !slices.Contains(sslModes, c.SSLMode) {
+		return "", fmt.Errorf("DB_SSLMODE must be one of %s", strings.Join(sslModes, ", "))
+	}
+
+	params := []string{
+		"host=" + connValue(c.Host),
+		fmt.Sprintf("port=%d", c.Port),
+		"user=" + connValue(c.User),
+		"password=" + connValue(c.Password),
+		"dbname=" + connValue(c.Name),
+		"sslmode=" + c.SSLMode,
+	}
+	for _, p := range []struct{ key, value string }{
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	} {
+		if p.value != "" {
+			params = append(params, p.key+"="+connValue(p.value))
+		}
+	}
+	return strings.Join(params, " "), nil
+}
+
+// connValue quotes a keyword/value connection string value so paths and
+// passwords may contain spaces and quotes.
+func connValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func openDB(cfg DBConfig, logger *slog.Logger) (*DB, error) {
+	connStr, err := cfg.connString()
+	if err != nil {
+		return nil, err
+	}
+
+	return connect(connStr, cfg.WarmupConns, logger)
+}
+
+// openReplica connects to the read replica at DB_REPLICA_DSN, or returns
+// nil when none is configured.
+func openReplica(cfg DBConfig, logger *slog.Logger) (*DB, error) {
+	if cfg.ReplicaDSN == "" {
+		return nil, nil
+	}
+	return connect(cfg.ReplicaDSN, cfg.WarmupConns, logger.With("db", "replica"))
+}
+
+func connect(connStr string, warmupConns int, logger *slog.Logger) (*DB, error) {
+	conn, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if warmupConns > 0 {
+		warmUp(conn, warmupConns, logger)
+	}
+
+	return &DB{DB: conn, logger: logger}, nil
+}
+
+const warmupTimeout = 10 * time.Second
+
+// warmUp opens n connections at once and returns them to the pool, so the
+// first requests after a deploy do not pay for connection setup. The idle
+// limit is raised to n, otherwise the pool would close most of them again.
+// Failures are only logged; the server works without a warm pool.
+func warmUp(conn *sql.DB, n int, logger *slog.Logger) {
+	conn.SetMaxIdleConns(max(n, 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+
+	errs := make(chan error, n)
+	conns := make(chan *sql.Conn, n)
+	for range n {
+		go func() {
+			c, err := conn.Conn(ctx)
+			if err == nil {
+				err = c.QueryRowContext(ctx, `SELECT 1`).Scan(new(int))
+				conns <- c
+			}
+			errs <- err
+		}()
+	}
+
+	var failed int
+	for range n {
+		if err := <-errs; err != nil {
+			failed++
+			logger.Warn("db warmup connection failed", "err", err)
+		}
+	}
+	close(conns)
+	for c := range conns {
+		c.Close()
+	}
+	logger.Info("db connections warmed up", "opened", n-failed, "requested", n)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := d.DB.ExecContext(ctx, query, args...)
+	logQuery(d.logger, query, start, err)
+	return res, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	logQuery(d.logger, query, start, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := d.DB.QueryRowContext(ctx, query, args...)
+	logQuery(d.logger, query, start, row.Err())
+	return row
+}
+
+// Tx is a transaction whose statements are timed and logged like those run
+// through DB. Its statements run under the context it was begun with, so
+// they are cancelled together with the request that started it.
+type Tx struct {
+	*sql.Tx
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+// begin starts a transaction on db. Its statements are logged when db is a
+// *DB; other Database implementations, such as a bare *sql.DB in tests,
+// get no logger.
+func begin(ctx context.Context, db Database, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tx{Tx: tx, ctx: ctx}
+	if d, ok := db.(*DB); ok {
+		t.logger = d.logger
+	}
+	return t, nil
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.ExecContext(t.ctx, query, args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.QueryContext(t.ctx, query, args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.QueryRowContext(t.ctx, query, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := t.Tx.ExecContext(ctx, query, args...)
+	logQuery(t.logger, query, start, err)
+	return res, err
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.Tx.QueryContext(ctx, query, args...)
+	logQuery(t.logger, query, start, err)
+	return rows, err
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.Tx.QueryRowContext(ctx, query, args...)
+	logQuery(t.logger, query, start, row.Err())
+	return row
+}
+
+func (t *Tx) Prepare(query string) (*Stmt, error) {
+	start := time.Now()
+	stmt, err := t.Tx.PrepareContext(t.ctx, query)
+	logQuery(t.logger, query, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{Stmt: stmt, ctx: t.ctx, query: query, logger: t.logger}, nil
+}
+
+// Stmt is a prepared statement of a Tx; every execution is logged with the
+// statement text.
+type Stmt struct {
+	*sql.Stmt
+	ctx    context.Context
+	query  string
+	logger *slog.Logger
+}
+
+func (s *Stmt) Exec(args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := s.Stmt.ExecContext(s.ctx, args...)
+	logQuery(s.logger, s.query, start, err)
+	return res, err
+}
+
+func logQuery(logger *slog.Logger, query string, start time.Time, err error) {
+	if logger == nil || !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	logger.Debug("sql query",
+		"query", strings.Join(strings.Fields(query), " "),
+		"duration", time.Since(start),
+		"error", err,
+	)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// validateDependencies checks that every depends_on entry names a test case
+// that already exists or is part of the same batch, and that the batch does
+// not introduce a cycle. Existing cases can only depend on other existing
+// cases, so a cycle has to pass through the batch itself.
+func (s *Server) validateDependencies(ctx context.Context, testCases []TestCase) ([]BatchRowError, error) {
+	inBatch := make(map[uuid.UUID]int, len(testCases))
+	for i, tc := range testCases {
+		if tc.ID != uuid.Nil {
+			inBatch[tc.ID] = i
+		}
+	}
+
+	var external []uuid.UUID
+	for _, tc := range testCases {
+		for _, dep := range tc.DependsOn {
+			if _, ok := inBatch[dep]; !ok {
+				external = append(external, dep)
+			}
+		}
+	}
+
+	existing, err := s.idSet(ctx, `SELECT id FROM test_cases WHERE id = ANY($1)`, external)
+	if err != nil {
+		return nil, err
+	}
+
+	var rowErrors []BatchRowError
+	for i, tc := range testCases {
+		for _, dep := range tc.DependsOn {
+			if _, ok := inBatch[dep]; !ok && !existing[dep] {
+				rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "depends_on",
+					Error: fmt.Sprintf("depends_on references unknown test case %s", dep)})
+			}
+		}
+	}
+
+	for _, i := range cycleMembers(testCases, inBatch) {
+		rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "depends_on", Error: "depends_on contains a cycle"})
+	}
+
+	return rowErrors, nil
+}
+
+// cycleMembers returns, in ascending order, the index of every batch row
+// that lies on a depends_on cycle within the batch. Rows that merely depend
+// on a cycle are not on it and are not returned. It finds the strongly
+// connected components with Tarjan's algorithm; a component is a cycle when
+// it has more than one row or a row that depends on itself.
+func cycleMembers(testCases []TestCase, inBatch map[uuid.UUID]int) []int {
+	n := len(testCases)
+	index := make([]int, n)
+	lowlink := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	next := 0
+	onCycle := make([]bool, n)
+
+	var connect func(i int)
+	connect = func(i int) {
+		index[i], lowlink[i] = next, next
+		next++
+		stack = append(stack, i)
+		onStack[i] = true
+
+		for _, dep := range testCases[i].DependsOn {
+			j, ok := inBatch[dep]
+			if !ok {
+				continue
+			}
+			if j == i {
+				onCycle[i] = true
+			}
+			if index[j] == -1 {
+				connect(j)
+				lowlink[i] = min(lowlink[i], lowlink[j])
+			} else if onStack[j] {
+				lowlink[i] = min(lowlink[i], index[j])
+			}
+		}
+
+		if lowlink[i] != index[i] {
+			return
+		}
+		var component []int
+		for {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[j] = false
+			component = append(component, j)
+			if j == i {
+				break
+			}
+		}
+		if len(component) > 1 {
+			for _, j := range component {
+				onCycle[j] = true
+			}
+		}
+	}
+	for i := range testCases {
+		if index[i] == -1 {
+			connect(i)
+		}
+	}
+
+	var members []int
+	for i, ok := range onCycle {
+		if ok {
+			members = append(members, i)
+		}
+	}
+	return members
+}
+
+// orderByDependencies returns cases so that every case comes after the cases
+// it depends on within the same run, keeping the original order otherwise.
+func orderByDependencies(cases []runnableCase) []runnableCase {
+	index := make(map[uuid.UUID]int, len(cases))
+	for i, c := range cases {
+		index[c.id] = i
+	}
+
+	ordered := make([]runnableCase, 0, len(cases))
+	placed := make([]bool, len(cases))
+	var place func(i int, path map[int]bool)
+	place = func(i int, path map[int]bool) {
+		if placed[i] || path[i] {
+			return
+		}
+		path[i] = true
+		for _, dep := range cases[i].dependsOn {
+			if j, ok := index[dep]; ok {
+				place(j, path)
+			}
+		}
+		delete(path, i)
+		placed[i] = true
+		ordered = append(ordered, cases[i])
+	}
+	for i := range cases {
+		place(i, map[int]bool{})
+	}
+	return ordered
+}
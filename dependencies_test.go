@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// depGraph builds n cases with fresh IDs; edges[i] lists the indexes case i
+// depends on, and a negative index stands for a case outside the batch.
+func depGraph(n int, edges map[int][]int) ([]uuid.UUID, [][]uuid.UUID) {
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	deps := make([][]uuid.UUID, n)
+	for i, targets := range edges {
+		for _, j := range targets {
+			if j < 0 {
+				deps[i] = append(deps[i], uuid.New())
+			} else {
+				deps[i] = append(deps[i], ids[j])
+			}
+		}
+	}
+	return ids, deps
+}
+
+func TestCycleMembers(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		n     int
+		edges map[int][]int
+		want  []int
+	}{
+		{"no dependencies", 3, nil, nil},
+		{"chain", 3, map[int][]int{0: {1}, 1: {2}}, nil},
+		{"diamond", 4, map[int][]int{0: {1, 2}, 1: {3}, 2: {3}}, nil},
+		{"outside the batch", 2, map[int][]int{0: {-1}, 1: {0, -1}}, nil},
+		{"self dependency", 2, map[int][]int{1: {1}}, []int{1}},
+		{"two-row cycle", 3, map[int][]int{0: {2}, 2: {0}}, []int{0, 2}},
+		{"dependent of a cycle is not on it", 4, map[int][]int{0: {1}, 1: {2}, 2: {1}, 3: {0}}, []int{1, 2}},
+		{"cycle with a tail into it", 4, map[int][]int{3: {0}, 0: {1}, 1: {2}, 2: {0}}, []int{0, 1, 2}},
+		{"two separate cycles", 5, map[int][]int{0: {1}, 1: {0}, 2: {3}, 3: {4}, 4: {2}}, []int{0, 1, 2, 3, 4}},
+		{"cycles sharing a row", 4, map[int][]int{0: {1}, 1: {0, 2}, 2: {1}, 3: {2}}, []int{0, 1, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, deps := depGraph(tc.n, tc.edges)
+			testCases := make([]TestCase, tc.n)
+			inBatch := make(map[uuid.UUID]int, tc.n)
+			for i, id := range ids {
+				testCases[i] = TestCase{ID: id, DependsOn: deps[i]}
+				inBatch[id] = i
+			}
+
+			if got := cycleMembers(testCases, inBatch); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("cycleMembers = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOrderByDependencies(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		n     int
+		edges map[int][]int
+		want  []int
+	}{
+		{"no dependencies keeps order", 3, nil, []int{0, 1, 2}},
+		{"dependency moves first", 3, map[int][]int{0: {2}}, []int{2, 0, 1}},
+		{"chain", 3, map[int][]int{0: {1}, 1: {2}}, []int{2, 1, 0}},
+		{"diamond", 4, map[int][]int{0: {1, 2}, 1: {3}, 2: {3}}, []int{3, 1, 2, 0}},
+		{"already ordered", 3, map[int][]int{1: {0}, 2: {1}}, []int{0, 1, 2}},
+		{"outside the run is ignored", 2, map[int][]int{0: {-1}}, []int{0, 1}},
+		{"cycle still places every case once", 3, map[int][]int{0: {1}, 1: {0}}, []int{1, 0, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, deps := depGraph(tc.n, tc.edges)
+			cases := make([]runnableCase, tc.n)
+			for i, id := range ids {
+				cases[i] = runnableCase{id: id, dependsOn: deps[i]}
+			}
+
+			var got []int
+			for _, c := range orderByDependencies(cases) {
+				for i, id := range ids {
+					if c.id == id {
+						got = append(got, i)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("order = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	encryptMarker   = "$encrypt"
+	encryptedMarker = "$encrypted"
+)
+
+// fieldCipher encrypts values inside json_data that the client wraps as
+// {"$encrypt": <value>}. They are stored as {"$encrypted": "<version>:<data>"}
+// and unwrapped back to {"$encrypt": <value>} on read.
+//
+// Keys come from JSONDATA_ENCRYPTION_KEYS as "version:base64key" pairs; the
+// first one encrypts, all of them decrypt. To rotate, prepend a new version
+// and keep the old ones until every stored value has been rewritten.
+type fieldCipher struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func newFieldCipher(spec string) (*fieldCipher, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	fc := &fieldCipher{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		version, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected version:base64key", entry)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", version, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", version, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", version, err)
+		}
+
+		if fc.primary == "" {
+			fc.primary = version
+		}
+		fc.keys[version] = aead
+	}
+	return fc, nil
+}
+
+func (fc *fieldCipher) encrypt(plaintext []byte) (string, error) {
+	aead := fc.keys[fc.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(fc.primary))
+	return fc.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (fc *fieldCipher) decrypt(token string) ([]byte, error) {
+	version, encoded, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	aead, ok := fc.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown key version %q", version)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(version))
+}
+
+func (s *Server) sealJSONData(data json.RawMessage) (json.RawMessage, error) {
+	return transformJSON(data, encryptMarker, func(value any) (any, error) {
+		if s.fieldCipher == nil {
+			return nil, fmt.Errorf("json_data contains %s but encryption is not configured", encryptMarker)
+		}
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		token, err := s.fieldCipher.encrypt(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{encryptedMarker: token}, nil
+	})
+}
+
+func (s *Server) openJSONData(data json.RawMessage) (json.RawMessage, error) {
+	if s.fieldCipher == nil {
+		return data, nil
+	}
+	return transformJSON(data, encryptedMarker, func(value any) (any, error) {
+		token, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("malformed encrypted value")
+		}
+		plaintext, err := s.fieldCipher.decrypt(token)
+		if err != nil {
+			return nil, err
+		}
+		var decoded any
+		if err := decodeJSON(plaintext, &decoded); err != nil {
+			return nil, err
+		}
+		return map[string]any{encryptMarker: decoded}, nil
+	})
+}
+
+func transformJSON(data json.RawMessage, marker string, fn func(any) (any, error)) (json.RawMessage, error) {
+	if len(data) == 0 || !bytes.Contains(data, []byte(marker)) {
+		return data, nil
+	}
+
+	var v any
+	if err := decodeJSON(data, &v); err != nil {
+		return nil, err
+	}
+
+	v, err := walkMarked(v, marker, fn)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func walkMarked(v any, marker string, fn func(any) (any, error)) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		if inner, ok := val[marker]; ok && len(val) == 1 {
+			return fn(inner)
+		}
+		for k, item := range val {
+			replaced, err := walkMarked(item, marker, fn)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = replaced
+		}
+	case []any:
+		for i, item := range val {
+			replaced, err := walkMarked(item, marker, fn)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = replaced
+		}
+	}
+	return v, nil
+}
+
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
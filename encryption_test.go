@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func mustFieldCipher(t *testing.T, spec string) *fieldCipher {
+	t.Helper()
+	fc, err := newFieldCipher(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+func TestNewFieldCipher(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"no version", testKey(1), "expected version:base64key"},
+		{"empty version", ":" + testKey(1), "expected version:base64key"},
+		{"bad base64", "v1:not-base64!", "key v1"},
+		{"bad key length", "v1:" + base64.StdEncoding.EncodeToString([]byte("short")), "key v1"},
+		{"bad second entry", "v2:" + testKey(2) + ",v1", "expected version:base64key"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newFieldCipher(tc.spec)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.err)
+			}
+		})
+	}
+
+	if fc, err := newFieldCipher("  "); fc != nil || err != nil {
+		t.Errorf("empty spec = %v, %v; want nil, nil", fc, err)
+	}
+}
+
+func TestFieldCipherRotation(t *testing.T) {
+	old := mustFieldCipher(t, "v1:"+testKey(1))
+	rotated := mustFieldCipher(t, "v2:"+testKey(2)+", v1:"+testKey(1))
+
+	oldToken, err := old.encrypt([]byte(`"secret"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := rotated.encrypt([]byte(`"secret"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(oldToken, "v1:") || !strings.HasPrefix(newToken, "v2:") {
+		t.Fatalf("tokens = %q, %q; want v1: and v2: prefixes", oldToken, newToken)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		plaintext, err := rotated.decrypt(token)
+		if err != nil {
+			t.Errorf("decrypt(%q): %v", token, err)
+		} else if string(plaintext) != `"secret"` {
+			t.Errorf("decrypt(%q) = %s", token, plaintext)
+		}
+	}
+
+	if _, err := old.decrypt(newToken); err == nil || !strings.Contains(err.Error(), "unknown key version") {
+		t.Errorf("decrypting a v2 value without the v2 key: err = %v", err)
+	}
+}
+
+func TestFieldCipherDecryptFails(t *testing.T) {
+	fc := mustFieldCipher(t, "v1:"+testKey(1))
+	token, err := fc.encrypt([]byte(`"secret"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, encoded, _ := strings.Cut(token, ":")
+	sealed, _ := base64.StdEncoding.DecodeString(encoded)
+	sealed[len(sealed)-1] ^= 0xff
+
+	for _, tc := range []struct {
+		name   string
+		cipher *fieldCipher
+		token  string
+	}{
+		{"wrong key", mustFieldCipher(t, "v1:"+testKey(9)), token},
+		{"missing key", mustFieldCipher(t, "v2:"+testKey(2)), token},
+		{"version swapped", mustFieldCipher(t, "v2:"+testKey(1)), "v2:" + encoded},
+		{"tampered", fc, "v1:" + base64.StdEncoding.EncodeToString(sealed)},
+		{"no version", fc, encoded},
+		{"bad base64", fc, "v1:!!!"},
+		{"too short", fc, "v1:" + base64.StdEncoding.EncodeToString([]byte("abc"))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if plaintext, err := tc.cipher.decrypt(tc.token); err == nil {
+				t.Errorf("decrypt = %s, want error", plaintext)
+			}
+		})
+	}
+}
+
+func TestSealAndOpenJSONData(t *testing.T) {
+	s := &Server{fieldCipher: mustFieldCipher(t, "v1:"+testKey(1))}
+	data := json.RawMessage(`{"url":"https://example.com","auth":{"$encrypt":{"token":"abc","n":1}},"list":[{"$encrypt":"x"}]}`)
+
+	sealed, err := s.sealJSONData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte(`"abc"`)) || bytes.Contains(sealed, []byte(`"`+encryptMarker+`"`)) {
+		t.Fatalf("sealed data still contains plaintext: %s", sealed)
+	}
+
+	opened, err := s.openJSONData(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want any
+	if err := json.Unmarshal(opened, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("opened = %s, want %s", opened, data)
+	}
+
+	s.fieldCipher = mustFieldCipher(t, "v2:"+testKey(2))
+	if _, err := s.openJSONData(sealed); err == nil {
+		t.Error("opening with a missing key succeeded")
+	}
+
+	s.fieldCipher = nil
+	if _, err := s.sealJSONData(data); err == nil {
+		t.Error("sealing without configured keys succeeded")
+	}
+}
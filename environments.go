@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// Environment is a named set of variables for a project. Placeholders such
+// as ${BASE_URL} in test case json_data are replaced with its values when a
+// run selects the environment with ?environment=<name>.
+type Environment struct {
+	ID        uuid.UUID         `json:"id"`
+	ProjectID uuid.UUID         `json:"project_id"`
+	Name      string            `json:"name"`
+	Variables map[string]string `json:"variables"`
+}
+
+var (
+	placeholderPattern  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+const maxEnvironmentNameLength = 100
+
+func (e *Environment) validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(e.Name) > maxEnvironmentNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxEnvironmentNameLength)
+	}
+	if e.Variables == nil {
+		e.Variables = map[string]string{}
+	}
+	for name := range e.Variables {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// expandPlaceholders substitutes ${NAME} in the string values of data.
+// Only strings are rewritten, so a value can never break the JSON
+// structure. An unknown variable is an error rather than left in place.
+func expandPlaceholders(data json.RawMessage, vars map[string]string) (json.RawMessage, error) {
+	if len(data) == 0 || !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var missing string
+	var expand func(any) any
+	expand = func(v any) any {
+		switch t := v.(type) {
+		case string:
+			return placeholderPattern.ReplaceAllStringFunc(t, func(m string) string {
+				name := placeholderPattern.FindStringSubmatch(m)[1]
+				value, ok := vars[name]
+				if !ok && missing == "" {
+					missing = name
+				}
+				return value
+			})
+		case map[string]any:
+			for k, x := range t {
+				t[k] = expand(x)
+			}
+		case []any:
+			for i, x := range t {
+				t[i] = expand(x)
+			}
+		}
+		return v
+	}
+	v = expand(v)
+	if missing != "" {
+		return nil, fmt.Errorf("variable %s is not defined", missing)
+	}
+	return json.Marshal(v)
+}
+
+// environmentVariables loads the variables of the environment called name
+// for each of projectIDs. Projects without such an environment are absent
+// from the result.
+func environmentVariables(q interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, name string, projectIDs []uuid.UUID) (map[uuid.UUID]map[string]string, error) {
+	rows, err := q.Query(`SELECT project_id, variables FROM project_environments WHERE name = $1 AND project_id = ANY($2)`,
+		name, pq.Array(projectIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vars := make(map[uuid.UUID]map[string]string)
+	for rows.Next() {
+		var projectID uuid.UUID
+		var data []byte
+		if err := rows.Scan(&projectID, &data); err != nil {
+			return nil, err
+		}
+		m := map[string]string{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("variables of environment %s: %w", name, err)
+		}
+		vars[projectID] = m
+	}
+	return vars, rows.Err()
+}
+
+// applyEnvironment substitutes the variables of the named environment into
+// the json_data of cases. Cases whose project lacks the environment, or that
+// reference an undefined variable, get a setupErr instead.
+func (s *Server) applyEnvironment(tx *Tx, name string, cases []runnableCase) error {
+	projectIDs := make([]uuid.UUID, 0, len(cases))
+	for _, c := range cases {
+		projectIDs = append(projectIDs, c.projectID)
+	}
+	vars, err := environmentVariables(tx, name, projectIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range cases {
+		c := &cases[i]
+		if c.setupErr != nil {
+			continue
+		}
+		projectVars, ok := vars[c.projectID]
+		if !ok {
+			c.setupErr = fmt.Errorf("environment %s is not defined for project %s", name, c.projectID)
+			continue
+		}
+		if c.jsonData, err = expandPlaceholders(c.jsonData, projectVars); err != nil {
+			c.setupErr = fmt.Errorf("json_data: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanEnvironment(row interface{ Scan(...any) error }) (Environment, error) {
+	var e Environment
+	var data []byte
+	if err := row.Scan(&e.ID, &e.ProjectID, &e.Name, &data); err != nil {
+		return e, err
+	}
+	e.Variables = map[string]string{}
+	return e, json.Unmarshal(data, &e.Variables)
+}
+
+// projectParam parses :projectId and checks the caller may see the project,
+// answering the request itself when they may not.
+func (s *Server) projectParam(w http.ResponseWriter, r *http.Request, ps httprouter.Params, userID uuid.UUID, role string) (uuid.UUID, bool) {
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return uuid.Nil, false
+	}
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return uuid.Nil, false
+	}
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
+		return uuid.Nil, false
+	}
+	return projectID, true
+}
+
+func (s *Server) listEnvironments(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, role)
+	if !ok {
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `SELECT id, project_id, name, variables FROM project_environments WHERE project_id = $1 ORDER BY name`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	environments := []Environment{}
+	for rows.Next() {
+		e, err := scanEnvironment(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		environments = append(environments, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(environments)
+}
+
+func (s *Server) createEnvironment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+
+	var e Environment
+	if err := decodeBody(r, &e); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := e.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	e.ID = uuid.New()
+	e.ProjectID = projectID
+
+	data, err := json.Marshal(e.Variables)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	_, err = s.db.ExecContext(r.Context(), `INSERT INTO project_environments (id, project_id, name, variables) VALUES ($1, $2, $3, $4)`,
+		e.ID, e.ProjectID, e.Name, data)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+func (s *Server) updateEnvironment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	environmentID, err := uuid.Parse(ps.ByName("environmentId"))
+	if err != nil {
+		writeError(w, validationError("Invalid environment ID"))
+		return
+	}
+
+	var e Environment
+	if err := decodeBody(r, &e); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := e.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	data, err := json.Marshal(e.Variables)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err = scanEnvironment(s.db.QueryRowContext(r.Context(), `
+		UPDATE project_environments SET name = $1, variables = $2
+		WHERE id = $3 AND project_id = $4
+		RETURNING id, project_id, name, variables`, e.Name, data, environmentID, projectID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Environment not found"))
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+func (s *Server) deleteEnvironment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	environmentID, err := uuid.Parse(ps.ByName("environmentId"))
+	if err != nil {
+		writeError(w, validationError("Invalid environment ID"))
+		return
+	}
+
+	res, err := s.db.ExecContext(r.Context(), `DELETE FROM project_environments WHERE id = $1 AND project_id = $2`, environmentID, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Environment not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
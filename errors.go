@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// Domain errors. Service functions return them, usually wrapped with a
+// client-facing message by the helpers below, and writeError turns them
+// into a status code so handlers stay free of HTTP decisions.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+	ErrConflict   = errors.New("conflict")
+)
+
+// domainError carries the message shown to the client; errors.Is sees the
+// sentinel it wraps.
+type domainError struct {
+	kind error
+	msg  string
+}
+
+func (e *domainError) Error() string { return e.msg }
+func (e *domainError) Unwrap() error { return e.kind }
+
+func notFoundError(msg string) error   { return &domainError{ErrNotFound, msg} }
+func forbiddenError(msg string) error  { return &domainError{ErrForbidden, msg} }
+func validationError(msg string) error { return &domainError{ErrValidation, msg} }
+func conflictError(msg string) error   { return &domainError{ErrConflict, msg} }
+
+var domainErrorStatus = []struct {
+	kind   error
+	status int
+}{
+	{ErrNotFound, http.StatusNotFound},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrValidation, http.StatusBadRequest},
+	{ErrConflict, http.StatusConflict},
+}
+
+// writeError is the single place errors from the service layer become HTTP
+// responses: domain errors map to their status, database errors go through
+// dbErrorStatus and anything else is a 500.
+func writeError(w http.ResponseWriter, err error) {
+	for _, d := range domainErrorStatus {
+		if errors.Is(err, d.kind) {
+			http.Error(w, err.Error(), d.status)
+			return
+		}
+	}
+	writeDBError(w, err)
+}
+
+func dbErrorStatus(err error) (int, string) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		return http.StatusConflict, fmt.Sprintf("Resource already exists (%s)", pqErr.Constraint)
+	case "foreign_key_violation":
+		if pqErr.Detail != "" {
+			return http.StatusBadRequest, fmt.Sprintf("Referenced resource not found: %s", pqErr.Detail)
+		}
+		return http.StatusBadRequest, fmt.Sprintf("Referenced resource not found (%s)", pqErr.Constraint)
+	case "not_null_violation":
+		return http.StatusBadRequest, fmt.Sprintf("Missing required field %s", pqErr.Column)
+	case "check_violation":
+		return http.StatusBadRequest, fmt.Sprintf("Invalid value (%s)", pqErr.Constraint)
+	case "invalid_text_representation", "string_data_right_truncation":
+		return http.StatusBadRequest, pqErr.Message
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// batchRowDBError is a database error raised while writing one row of a
+// batch. It unwraps to the driver error so retries still recognise it.
+type batchRowDBError struct {
+	index int
+	err   error
+}
+
+func (e *batchRowDBError) Error() string { return fmt.Sprintf("row %d: %v", e.index, e.err) }
+func (e *batchRowDBError) Unwrap() error { return e.err }
+
+// dbRowError describes a database error on row index of a batch, naming
+// the column and constraint involved when Postgres reports them.
+func dbRowError(index int, err error) BatchRowError {
+	_, msg := dbErrorStatus(err)
+	rowErr := BatchRowError{Index: index, Error: msg}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		rowErr.Field = pqErr.Column
+		rowErr.Constraint = pqErr.Constraint
+	}
+	return rowErr
+}
+
+func writeDBError(w http.ResponseWriter, err error) {
+	status, msg := dbErrorStatus(err)
+	http.Error(w, msg, status)
+}
+
+var errUserDeactivated = forbiddenError("user is deactivated")
+
+// writeAuthError reports a failed authentication. Deactivated accounts get
+// 403 so clients can tell them apart from bad credentials.
+func writeAuthError(w http.ResponseWriter, err error) {
+	status := http.StatusUnauthorized
+	if errors.Is(err, ErrForbidden) {
+		status = http.StatusForbidden
+	}
+	http.Error(w, fmt.Sprintf("Authentication failed: %v", err), status)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql driver that answers statements from canned
+// responses, so handlers can be exercised without PostgreSQL. A response is
+// chosen by the first registered fragment the statement contains;
+// statements nothing matches fail the test.
+type fakeDB struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	responses []*fakeResponse
+	execs     []fakeExec
+}
+
+type fakeResponse struct {
+	fragment string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeExec records a statement run through Exec.
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	f := &fakeDB{t: t}
+	db := sql.OpenDB(fakeConnector{f})
+	t.Cleanup(func() { db.Close() })
+	return f, db
+}
+
+// on answers statements containing fragment with rows of columns.
+func (f *fakeDB) on(fragment string, columns []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, &fakeResponse{fragment: fragment, columns: columns, rows: rows, affected: 1})
+}
+
+// fail answers statements containing fragment with err.
+func (f *fakeDB) fail(fragment string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, &fakeResponse{fragment: fragment, err: err})
+}
+
+// executed returns the Exec statements containing fragment.
+func (f *fakeDB) executed(fragment string) []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []fakeExec
+	for _, e := range f.execs {
+		if strings.Contains(e.query, fragment) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (f *fakeDB) respond(query string) (*fakeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.responses {
+		if strings.Contains(query, r.fragment) {
+			return r, r.err
+		}
+	}
+	f.t.Errorf("unexpected statement: %s", strings.Join(strings.Fields(query), " "))
+	return nil, fmt.Errorf("fakedb: unexpected statement")
+}
+
+type fakeConnector struct{ db *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{c.db}, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: open through a connector")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	s.db.execs = append(s.db.execs, fakeExec{query: s.query, args: args})
+	s.db.mu.Unlock()
+
+	r, err := s.db.respond(s.query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(r.affected), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r, err := s.db.respond(s.query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: r.columns, rows: r.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type fieldKind int
+
+const (
+	fieldText fieldKind = iota
+	fieldUUID
+	fieldBool
+	fieldInt
+	fieldJSON
+	// fieldSealedJSON is json_data that may hold encrypted values.
+	fieldSealedJSON
+	fieldUUIDs
+	fieldStrings
+)
+
+// sparseField maps a JSON field that ?fields= may select to its column.
+type sparseField struct {
+	column string
+	kind   fieldKind
+}
+
+var projectFields = map[string]sparseField{
+	"id":                              {"id", fieldUUID},
+	"name":                            {"name", fieldText},
+	"description":                     {"description", fieldText},
+	"description_format":              {"description_format", fieldText},
+	"allow_duplicate_test_case_names": {"allow_duplicate_test_case_names", fieldBool},
+	"batch_notifications":             {"batch_notifications", fieldBool},
+	"is_archived":                     {"is_archived", fieldBool},
+	"metadata":                        {"metadata", fieldJSON},
+}
+
+var entityFields = map[string]sparseField{
+	"id":                 {"id", fieldUUID},
+	"name":               {"name", fieldText},
+	"description":        {"description", fieldText},
+	"description_format": {"description_format", fieldText},
+	"project_id":         {"project_id", fieldUUID},
+	"json_data":          {"json_data", fieldJSON},
+}
+
+var testCaseFields = map[string]sparseField{
+	"id":                 {"id", fieldUUID},
+	"name":               {"name", fieldText},
+	"description":        {"description", fieldText},
+	"description_format": {"description_format", fieldText},
+	"json_data":          {"json_data", fieldSealedJSON},
+	"entity_id":          {"entity_id", fieldUUID},
+	"project_id":         {"project_id", fieldUUID},
+	"requirement_id":     {"requirement_id", fieldText},
+	"assigned_to":        {"assigned_to", fieldUUID},
+	"priority":           {"priority", fieldText},
+	"severity":           {"severity", fieldText},
+	"depends_on":         {"depends_on", fieldUUIDs},
+	"tags":               {"tags", fieldStrings},
+	"timeout_ms":         {"timeout_ms", fieldInt},
+}
+
+// parseFields reads a ?fields=id,name list. It returns nil when the
+// parameter is absent, meaning the full representation.
+func parseFields(v string, fields map[string]sparseField) ([]string, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		if _, ok := fields[name]; !ok {
+			known := make([]string, 0, len(fields))
+			for k := range fields {
+				known = append(known, k)
+			}
+			slices.Sort(known)
+			return nil, fmt.Errorf("unknown field %q; fields are %s", name, strings.Join(known, ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("fields must name at least one field")
+	}
+	return names, nil
+}
+
+func sparseColumns(names []string, fields map[string]sparseField) string {
+	columns := make([]string, len(names))
+	for i, name := range names {
+		columns[i] = fields[name].column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// respondSparse runs query, which must select sparseColumns(names, fields),
+// and writes each row as an object holding only the named fields.
+func (s *Server) respondSparse(ctx context.Context, w http.ResponseWriter, db Database, names []string, fields map[string]sparseField, query string, args ...any) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []map[string]any{}
+	for rows.Next() {
+		item, err := s.scanSparse(rows, names, fields)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func (s *Server) scanSparse(rows *sql.Rows, names []string, fields map[string]sparseField) (map[string]any, error) {
+	dests := make([]any, len(names))
+	for i, name := range names {
+		switch fields[name].kind {
+		case fieldText:
+			dests[i] = new(sql.NullString)
+		case fieldUUID:
+			dests[i] = new(uuid.NullUUID)
+		case fieldBool:
+			dests[i] = new(bool)
+		case fieldInt:
+			dests[i] = new(sql.NullInt64)
+		case fieldJSON, fieldSealedJSON:
+			dests[i] = new([]byte)
+		case fieldUUIDs:
+			dests[i] = new([]uuid.UUID)
+		case fieldStrings:
+			dests[i] = new([]string)
+		}
+	}
+	scan := make([]any, len(dests))
+	for i, d := range dests {
+		switch d := d.(type) {
+		case *[]uuid.UUID:
+			scan[i] = pq.Array(d)
+		case *[]string:
+			scan[i] = pq.Array(d)
+		default:
+			scan[i] = d
+		}
+	}
+	if err := rows.Scan(scan...); err != nil {
+		return nil, err
+	}
+
+	item := make(map[string]any, len(names))
+	for i, name := range names {
+		switch d := dests[i].(type) {
+		case *sql.NullString:
+			item[name] = d.String
+		case *uuid.NullUUID:
+			if d.Valid {
+				item[name] = d.UUID
+			} else {
+				item[name] = nil
+			}
+		case *bool:
+			item[name] = *d
+		case *sql.NullInt64:
+			if d.Valid {
+				item[name] = d.Int64
+			} else {
+				item[name] = nil
+			}
+		case *[]byte:
+			data := json.RawMessage(*d)
+			if fields[name].kind == fieldSealedJSON {
+				var err error
+				if data, err = s.openJSONData(data); err != nil {
+					return nil, fmt.Errorf("decrypt %s: %w", name, err)
+				}
+			}
+			if len(data) == 0 {
+				data = json.RawMessage("null")
+			}
+			item[name] = data
+		case *[]uuid.UUID:
+			item[name] = append([]uuid.UUID{}, *d...)
+		case *[]string:
+			item[name] = append([]string{}, *d...)
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+type filterKind int
+
+const (
+	filterText filterKind = iota
+	filterUUID
+)
+
+type filterField struct {
+	column string
+	kind   filterKind
+}
+
+var filterOperators = map[string]string{
+	"=":  "=",
+	"!=": "<>",
+	"~":  "ILIKE",
+}
+
+// parseFilter turns an expression like `name~foo AND requirement_id=REQ-1`
+// into a parameterized WHERE fragment. Conditions are joined with AND only;
+// values may be bare words or double-quoted strings.
+func parseFilter(expr string, fields map[string]filterField, argOffset int) (string, []any, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return "", nil, nil
+	}
+
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var conds []string
+	var args []any
+	for i := 0; i < len(tokens); {
+		if len(conds) > 0 {
+			if !strings.EqualFold(tokens[i], "AND") {
+				return "", nil, fmt.Errorf("expected AND, got %q", tokens[i])
+			}
+			i++
+		}
+		if i+3 > len(tokens) {
+			return "", nil, fmt.Errorf("incomplete condition")
+		}
+
+		name, op, value := tokens[i], tokens[i+1], tokens[i+2]
+		i += 3
+
+		field, ok := fields[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown filter field %q", name)
+		}
+		sqlOp, ok := filterOperators[op]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown filter operator %q", op)
+		}
+
+		var arg any = value
+		switch field.kind {
+		case filterUUID:
+			if sqlOp == "ILIKE" {
+				return "", nil, fmt.Errorf("operator ~ is not supported for %q", name)
+			}
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid uuid for %q", name)
+			}
+			arg = id
+		case filterText:
+			if sqlOp == "ILIKE" {
+				arg = "%" + escapeLike(value) + "%"
+			}
+		}
+
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf("%s %s $%d", field.column, sqlOp, argOffset+len(args)))
+	}
+
+	return strings.Join(conds, " AND "), args, nil
+}
+
+func tokenizeFilter(expr string) ([]string, error) {
+	var tokens []string
+	rs := []rune(expr)
+	for i := 0; i < len(rs); {
+		switch c := rs[i]; {
+		case unicode.IsSpace(c):
+			i++
+		case c == '=' || c == '~':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '!':
+			if i+1 >= len(rs) || rs[i+1] != '=' {
+				return nil, fmt.Errorf("unexpected '!' at position %d", i)
+			}
+			tokens = append(tokens, "!=")
+			i += 2
+		case c == '"':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, sb.String())
+			i = j + 1
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(`=~!"`, rs[j]) {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type whereClause struct {
+	conds []string
+	args  []any
+}
+
+func (wc *whereClause) arg(v any) string {
+	wc.args = append(wc.args, v)
+	return fmt.Sprintf("$%d", len(wc.args))
+}
+
+func (wc *whereClause) add(cond string) {
+	if cond != "" {
+		wc.conds = append(wc.conds, cond)
+	}
+}
+
+func (wc *whereClause) addFilter(expr string, fields map[string]filterField) error {
+	cond, args, err := parseFilter(expr, fields, len(wc.args))
+	if err != nil {
+		return err
+	}
+	wc.args = append(wc.args, args...)
+	wc.add(cond)
+	return nil
+}
+
+func (wc *whereClause) String() string {
+	if len(wc.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(wc.conds, " AND ")
+}
+
+// parseSort accepts a comma-separated list of whitelisted keys, each
+// optionally prefixed with '-' for descending order. name is always used
+// as the final tie-breaker.
+func parseSort(param string, columns map[string]string) (string, error) {
+	var parts []string
+	for _, key := range strings.Split(param, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		dir := "ASC"
+		if strings.HasPrefix(key, "-") {
+			dir = "DESC"
+			key = key[1:]
+		}
+
+		column, ok := columns[key]
+		if !ok {
+			return "", fmt.Errorf("unknown sort field %q", key)
+		}
+		parts = append(parts, column+" "+dir)
+	}
+	return strings.Join(append(parts, "name ASC"), ", "), nil
+}
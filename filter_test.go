@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var testFilterFields = map[string]filterField{
+	"name":           {column: "tc.name", kind: filterText},
+	"requirement_id": {column: "tc.requirement_id", kind: filterText},
+	"entity_id":      {column: "tc.entity_id", kind: filterUUID},
+}
+
+func TestParseFilter(t *testing.T) {
+	entityID := uuid.New()
+
+	for _, tc := range []struct {
+		name      string
+		expr      string
+		argOffset int
+		where     string
+		args      []any
+	}{
+		{"empty", "   ", 0, "", nil},
+		{"equals", "name=login", 0, "tc.name = $1", []any{"login"}},
+		{"not equals", "name != login", 0, "tc.name <> $1", []any{"login"}},
+		{"ilike", "name~login", 0, "tc.name ILIKE $1", []any{"%login%"}},
+		{"ilike escapes wildcards", `name~"50%_off\\"`, 0, "tc.name ILIKE $1", []any{`%50\%\_off\\%`}},
+		{"quoted value", `name="smoke test"`, 0, "tc.name = $1", []any{"smoke test"}},
+		{"embedded quote", `name="say \"hi\""`, 0, "tc.name = $1", []any{`say "hi"`}},
+		{"operators in quotes", `name="a=b~c!=d"`, 0, "tc.name = $1", []any{"a=b~c!=d"}},
+		{"uuid", "entity_id=" + entityID.String(), 0, "tc.entity_id = $1", []any{entityID}},
+		{
+			"and chain", "name~login and requirement_id=REQ-1 AND entity_id!=" + entityID.String(), 0,
+			"tc.name ILIKE $1 AND tc.requirement_id = $2 AND tc.entity_id <> $3",
+			[]any{"%login%", "REQ-1", entityID},
+		},
+		{
+			"arg offset", "name=login AND requirement_id=REQ-1", 2,
+			"tc.name = $3 AND tc.requirement_id = $4",
+			[]any{"login", "REQ-1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			where, args, err := parseFilter(tc.expr, testFilterFields, tc.argOffset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if where != tc.where {
+				t.Errorf("where = %q, want %q", where, tc.where)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("args = %#v, want %#v", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{"unknown field", "password=secret", "unknown filter field"},
+		{"column name is not a field", "tc.name=login", "unknown filter field"},
+		{"unknown operator", "name login x", "unknown filter operator"},
+		{"missing AND", "name=a name=b", "expected AND"},
+		{"OR is not supported", "name=a OR name=b", "expected AND"},
+		{"trailing operator", "name=", "incomplete condition"},
+		{"trailing AND", "name=a AND", "incomplete condition"},
+		{"empty term", "name=a AND AND name=b", "unknown filter field"},
+		{"missing value", "name= AND requirement_id=REQ-1", "expected AND"},
+		{"unterminated quote", `name="login`, "unterminated string"},
+		{"lone bang", "name!login", "unexpected '!'"},
+		{"ilike on uuid", "entity_id~abc", "not supported"},
+		{"invalid uuid", "entity_id=abc", "invalid uuid"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			where, args, err := parseFilter(tc.expr, testFilterFields, 0)
+			if err == nil {
+				t.Fatalf("parseFilter(%q) = %q, %v; want error", tc.expr, where, args)
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("error = %q, want it to contain %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestTokenizeFilter(t *testing.T) {
+	for _, tc := range []struct {
+		expr   string
+		tokens []string
+	}{
+		{"name=login", []string{"name", "=", "login"}},
+		{"  name  !=  login  ", []string{"name", "!=", "login"}},
+		{"name~log AND x=y", []string{"name", "~", "log", "AND", "x", "=", "y"}},
+		{`name="a b"`, []string{"name", "=", "a b"}},
+		{`name="a \"b\" \\ c"`, []string{"name", "=", `a "b" \ c`}},
+		{`name=""`, []string{"name", "=", ""}},
+		{"naïve=ü", []string{"naïve", "=", "ü"}},
+	} {
+		tokens, err := tokenizeFilter(tc.expr)
+		if err != nil {
+			t.Errorf("tokenizeFilter(%q): %v", tc.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, tc.tokens) {
+			t.Errorf("tokenizeFilter(%q) = %q, want %q", tc.expr, tokens, tc.tokens)
+		}
+	}
+
+	for _, expr := range []string{`name="abc`, `name="abc\"`, "name!", "!"} {
+		if tokens, err := tokenizeFilter(expr); err == nil {
+			t.Errorf("tokenizeFilter(%q) = %q, want error", expr, tokens)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":   "plain",
+		"100%":    `100\%`,
+		"a_b":     `a\_b`,
+		`c:\path`: `c:\\path`,
+		`%_\`:     `\%\_\\`,
+	} {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func newTestServer(t *testing.T) (*Server, *fakeDB) {
+	t.Helper()
+	f, db := newFakeDB(t)
+	cfg := Config{MaxBatchSize: 100, MaxRunCases: 100, MaxJSONDepth: 32}
+	s, err := NewServer(db, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s, f
+}
+
+// tokenFor signs a token for a new active user with role and makes the
+// fake database report that user when the token is checked.
+func tokenFor(t *testing.T, s *Server, f *fakeDB, role string) string {
+	t.Helper()
+	token, err := s.jwtKeys.sign(&Claims{
+		UserID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	f.on("SELECT role, token_version, is_active FROM users", []string{"role", "token_version", "is_active"},
+		[]driver.Value{role, int64(0), true})
+	return token
+}
+
+func serve(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoginHandler(t *testing.T) {
+	userColumns := []string{"id", "email", "password", "role", "token_version", "is_active"}
+	hash, err := hashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		s, f := newTestServer(t)
+		userID := uuid.New()
+		f.on("FROM users WHERE lower(email) = $1", userColumns,
+			[]driver.Value{userID.String(), "tester@example.com", hash, testerRole, int64(0), true})
+
+		rec := serve(s, http.MethodPost, "/v1/login", "", `{"email": "Tester@Example.com", "password": "secret"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var resp struct{ Token string }
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		claims := &Claims{}
+		if err := s.jwtKeys.parse(resp.Token, claims); err != nil {
+			t.Fatalf("token does not verify: %v", err)
+		}
+		if claims.UserID != userID {
+			t.Errorf("token user = %s, want %s", claims.UserID, userID)
+		}
+	})
+
+	t.Run("validation failure", func(t *testing.T) {
+		s, _ := newTestServer(t)
+		rec := serve(s, http.MethodPost, "/v1/login", "", `{"email": "not an email", "password": "secret"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("FROM users WHERE lower(email) = $1", userColumns,
+			[]driver.Value{uuid.NewString(), "tester@example.com", hash, testerRole, int64(0), true})
+
+		rec := serve(s, http.MethodPost, "/v1/login", "", `{"email": "tester@example.com", "password": "wrong"}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("plaintext password column", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("FROM users WHERE lower(email) = $1", userColumns,
+			[]driver.Value{uuid.NewString(), "tester@example.com", "secret", testerRole, int64(0), true})
+
+		rec := serve(s, http.MethodPost, "/v1/login", "", `{"email": "tester@example.com", "password": "secret"}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("FROM users WHERE lower(email) = $1", userColumns)
+
+		rec := serve(s, http.MethodPost, "/v1/login", "", `{"email": "tester@example.com", "password": "wrong"}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestCreateProject(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+		f.on("INSERT INTO projects", nil)
+
+		rec := serve(s, http.MethodPost, "/v1/projects", token, `{"name": "Checkout"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var project Project
+		if err := json.Unmarshal(rec.Body.Bytes(), &project); err != nil {
+			t.Fatal(err)
+		}
+		if project.ID == uuid.Nil || project.Name != "Checkout" || project.DescriptionFormat != defaultDescriptionFormat {
+			t.Errorf("project = %+v", project)
+		}
+		if n := len(f.executed("INSERT INTO projects")); n != 1 {
+			t.Errorf("%d inserts, want 1", n)
+		}
+	})
+
+	t.Run("validation failure", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+
+		rec := serve(s, http.MethodPost, "/v1/projects", token, `{"name": "Checkout", "description_format": "pdf"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		if n := len(f.executed("INSERT INTO projects")); n != 0 {
+			t.Errorf("%d inserts, want 0", n)
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		s, _ := newTestServer(t)
+		rec := serve(s, http.MethodPost, "/v1/projects", "", `{"name": "Checkout"}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("wrong role", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testerRole)
+
+		rec := serve(s, http.MethodPost, "/v1/projects", token, `{"name": "Checkout"}`)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestAddEntity(t *testing.T) {
+	projectID := uuid.New()
+	body := `{"name": "Cart", "project_id": "` + projectID.String() + `"}`
+
+	t.Run("success", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+		f.on("SELECT EXISTS(SELECT 1 FROM projects", []string{"exists"}, []driver.Value{true})
+		f.on("INSERT INTO entities", nil)
+
+		rec := serve(s, http.MethodPost, "/v1/entities", token, body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var entity Entity
+		if err := json.Unmarshal(rec.Body.Bytes(), &entity); err != nil {
+			t.Fatal(err)
+		}
+		if entity.ID == uuid.Nil || entity.ProjectID != projectID {
+			t.Errorf("entity = %+v", entity)
+		}
+		if n := len(f.executed("INSERT INTO entities")); n != 1 {
+			t.Errorf("%d inserts, want 1", n)
+		}
+	})
+
+	t.Run("validation failure", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+
+		rec := serve(s, http.MethodPost, "/v1/entities", token,
+			`{"name": "Cart", "description_format": "pdf", "project_id": "`+projectID.String()+`"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+		f.on("SELECT EXISTS(SELECT 1 FROM projects", []string{"exists"}, []driver.Value{false})
+
+		rec := serve(s, http.MethodPost, "/v1/entities", token, body)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("wrong role", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testAnalystRole)
+
+		rec := serve(s, http.MethodPost, "/v1/entities", token, body)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestBatchUploadTestCases(t *testing.T) {
+	projectID, entityID := uuid.New(), uuid.New()
+	entityRow := func(f *fakeDB) {
+		f.on("FROM entities e JOIN projects p ON p.id = e.project_id", []string{"id", "project_id", "unique"},
+			[]driver.Value{entityID.String(), projectID.String(), false})
+	}
+	row := func(name string) string {
+		return `{"name": "` + name + `", "entity_id": "` + entityID.String() + `", "project_id": "` + projectID.String() + `"}`
+	}
+
+	t.Run("success", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testAnalystRole)
+		entityRow(f)
+		f.on("INSERT INTO test_cases", nil)
+
+		rec := serve(s, http.MethodPost, "/v1/testcases/batch", token, "["+row("Add item")+", "+row("Remove item")+"]")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var created []TestCase
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+		if len(created) != 2 {
+			t.Fatalf("created %d test cases, want 2", len(created))
+		}
+		if n := len(f.executed("INSERT INTO test_cases")); n != 2 {
+			t.Errorf("%d inserts, want 2", n)
+		}
+	})
+
+	t.Run("validation failure", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testAnalystRole)
+		entityRow(f)
+
+		rec := serve(s, http.MethodPost, "/v1/testcases/batch", token, "["+row("Add item")+", "+row(" ")+"]")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var resp BatchValidationErrors
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Index != 1 || resp.Errors[0].Field != "name" {
+			t.Errorf("errors = %+v, want one name error at index 1", resp.Errors)
+		}
+		if n := len(f.executed("INSERT INTO test_cases")); n != 0 {
+			t.Errorf("%d inserts, want 0", n)
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		s, _ := newTestServer(t)
+		rec := serve(s, http.MethodPost, "/v1/testcases/batch", "", "["+row("Add item")+"]")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+}
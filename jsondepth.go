@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// checkJSONDepth walks data token by token and fails as soon as objects
+// and arrays nest deeper than max, without building the decoded value.
+// A max below 1 disables the check.
+func checkJSONDepth(data json.RawMessage, max int) error {
+	if max < 1 || len(data) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > max {
+				return fmt.Errorf("json_data exceeds the maximum nesting depth of %d", max)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// junitExport renders a run as one <testsuite> per entity, which is what
+// CI tools group results by.
+func (s *Server) junitExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	detail, ok := s.loadRunDetail(w, r, ps)
+	if !ok {
+		return
+	}
+
+	name := detail.Label
+	if name == "" {
+		name = detail.RunID.String()
+	}
+	doc := junitTestSuites{Name: name}
+
+	suites := make(map[string]int)
+	for _, res := range detail.Results {
+		i, ok := suites[res.EntityName]
+		if !ok {
+			i = len(doc.Suites)
+			suites[res.EntityName] = i
+			doc.Suites = append(doc.Suites, junitTestSuite{
+				Name:      res.EntityName,
+				Timestamp: detail.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+			})
+		}
+		suite := &doc.Suites[i]
+
+		tc := junitTestCase{Name: res.TestCaseName, ClassName: res.EntityName}
+		if res.DurationMs != nil {
+			tc.Time = float64(*res.DurationMs) / 1000
+		}
+
+		switch res.Status {
+		case statusPassed:
+		case statusSkipped, statusCancelled:
+			tc.Skipped = &junitMessage{Message: detailMessage(res.Details, "reason", string(res.Status))}
+			suite.Skipped++
+		default:
+			tc.Failure = &junitMessage{Message: detailMessage(res.Details, "error", string(res.Status)), Body: string(res.Details)}
+			suite.Failures++
+		}
+
+		suite.Tests++
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	for _, suite := range doc.Suites {
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Time += suite.Time
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%s.xml"`, detail.RunID))
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(doc)
+}
+
+func detailMessage(details json.RawMessage, key, fallback string) string {
+	var fields map[string]any
+	if json.Unmarshal(details, &fields) == nil {
+		if msg, ok := fields[key].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return fallback
+}
+
+// JUnitImportResult lists what an import created. Cases that already
+// existed in their entity are reused rather than duplicated; RunID is set
+// when ?seed_results=true recorded the file's outcomes as a run.
+type JUnitImportResult struct {
+	ProjectID       uuid.UUID   `json:"project_id"`
+	EntitiesCreated []Entity    `json:"entities_created"`
+	Created         []TestCase  `json:"created"`
+	Existing        []uuid.UUID `json:"existing"`
+	RunID           *uuid.UUID  `json:"run_id,omitempty"`
+}
+
+// parseJUnit accepts both a <testsuites> document and a bare <testsuite>.
+func parseJUnit(data []byte) ([]junitTestSuite, error) {
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err == nil {
+		return doc.Suites, nil
+	}
+	var suite junitTestSuite
+	if err := xml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid JUnit XML: %w", err)
+	}
+	return []junitTestSuite{suite}, nil
+}
+
+func (tc junitTestCase) status() (RunStatus, string) {
+	switch {
+	case tc.Failure != nil:
+		return statusFailed, tc.Failure.Message
+	case tc.Error != nil:
+		return statusError, tc.Error.Message
+	case tc.Skipped != nil:
+		return statusSkipped, tc.Skipped.Message
+	}
+	return statusPassed, ""
+}
+
+type junitImportCase struct {
+	entity string
+	junitTestCase
+	runTime time.Time
+}
+
+// importJUnit creates test cases in ?project_id from a JUnit XML body. The
+// classname of each <testcase> (or its suite's name when it has none)
+// becomes the entity and name the test case; missing entities are created.
+// With ?seed_results=true the outcomes are also recorded as a completed run.
+func (s *Server) importJUnit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	projectID, err := uuid.Parse(q.Get("project_id"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+	allowed, err := s.canAccessProject(r.Context(), userID, testAnalystRole, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+	var seed bool
+	if v := q.Get("seed_results"); v != "" {
+		if seed, err = strconv.ParseBool(v); err != nil {
+			writeError(w, validationError("seed_results must be true or false"))
+			return
+		}
+	}
+
+	if r.Body == nil {
+		writeError(w, validationError(errEmptyBody.Error()))
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	suites, err := parseJUnit(data)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	var cases []junitImportCase
+	seen := make(map[[2]string]bool)
+	now := time.Now()
+	for _, suite := range suites {
+		runTime := now
+		if t, err := time.Parse("2006-01-02T15:04:05", suite.Timestamp); err == nil {
+			runTime = t
+		}
+		for _, tc := range suite.Cases {
+			c := junitImportCase{entity: tc.ClassName, junitTestCase: tc, runTime: runTime}
+			if c.entity == "" {
+				c.entity = suite.Name
+			}
+			if strings.TrimSpace(c.Name) == "" || strings.TrimSpace(c.entity) == "" {
+				writeError(w, validationError("Every testcase needs a name and a classname or suite name"))
+				return
+			}
+			key := [2]string{c.entity, c.Name}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			cases = append(cases, c)
+		}
+	}
+	if len(cases) == 0 {
+		writeError(w, validationError("No testcases found"))
+		return
+	}
+	if len(cases) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("File has %d test cases, which exceeds the maximum of %d", len(cases), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var result JUnitImportResult
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		result = JUnitImportResult{ProjectID: projectID, EntitiesCreated: []Entity{}, Created: []TestCase{}, Existing: []uuid.UUID{}}
+
+		entities, err := junitEntities(tx, projectID)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(cases))
+		for i, c := range cases {
+			entityID, ok := entities[c.entity]
+			if !ok {
+				e := Entity{ID: uuid.New(), Name: c.entity, DescriptionFormat: defaultDescriptionFormat, ProjectID: projectID}
+				_, err := tx.Exec(`INSERT INTO entities (`+entityColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+					e.ID, e.Name, e.Description, e.DescriptionFormat, e.ProjectID, nil)
+				if err != nil {
+					return err
+				}
+				entities[c.entity] = e.ID
+				entityID = e.ID
+				result.EntitiesCreated = append(result.EntitiesCreated, e)
+			}
+
+			err := tx.QueryRow(`SELECT id FROM test_cases WHERE entity_id = $1 AND name = $2 ORDER BY id LIMIT 1`,
+				entityID, c.Name).Scan(&ids[i])
+			if err == nil {
+				result.Existing = append(result.Existing, ids[i])
+				continue
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+
+			tc := TestCase{
+				ID:                uuid.New(),
+				Name:              c.Name,
+				DescriptionFormat: defaultDescriptionFormat,
+				EntityID:          entityID,
+				ProjectID:         projectID,
+				Priority:          defaultLevel,
+				Severity:          defaultLevel,
+				DependsOn:         []uuid.UUID{},
+				Tags:              []string{},
+			}
+			_, err = tx.Exec(`
+				INSERT INTO test_cases (id, name, description, description_format, json_data, entity_id, project_id, requirement_id, priority, severity, depends_on, tags, timeout_ms)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+				tc.ID, tc.Name, tc.Description, tc.DescriptionFormat, nil, tc.EntityID, tc.ProjectID, tc.RequirementID, tc.Priority, tc.Severity,
+				pq.Array(tc.DependsOn), pq.Array(tc.Tags), tc.TimeoutMs)
+			if err != nil {
+				return err
+			}
+			ids[i] = tc.ID
+			result.Created = append(result.Created, tc)
+		}
+
+		if !seed {
+			return nil
+		}
+		runID := uuid.New()
+		_, err = tx.Exec(`
+			INSERT INTO test_runs (id, label, status, test_case_ids, finished_at)
+			VALUES ($1, $2, $3, $4, NOW())`,
+			runID, "JUnit import", runCompleted, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		for i, c := range cases {
+			status, message := c.status()
+			var details json.RawMessage
+			if message != "" {
+				key := "error"
+				if status == statusSkipped {
+					key = "reason"
+				}
+				details, _ = json.Marshal(map[string]string{key: message})
+			}
+			var duration sql.NullInt64
+			if status != statusSkipped {
+				duration = sql.NullInt64{Int64: int64(c.Time * 1000), Valid: true}
+			}
+			_, err := tx.Exec(`
+				INSERT INTO test_run_results (run_id, test_case_id, status, run_time, duration_ms, details)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+				runID, ids[i], status, c.runTime, duration, nullableJSON(details))
+			if err != nil {
+				return err
+			}
+		}
+		result.RunID = &runID
+		return nil
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// junitEntities maps the names of the project's entities to their IDs. With
+// duplicate names the oldest-sorting ID wins, so repeated imports agree.
+func junitEntities(tx *Tx, projectID uuid.UUID) (map[string]uuid.UUID, error) {
+	rows, err := tx.Query(`SELECT DISTINCT ON (name) name, id FROM entities WHERE project_id = $1 ORDER BY name, id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var name string
+		var id uuid.UUID
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		entities[name] = id
+	}
+	return entities, rows.Err()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtKeyring signs tokens with the primary key and records its id in the
+// "kid" header. Verification accepts any configured key, so a secret can be
+// rotated by prepending a new key to JWT_SIGNING_KEYS and dropping the old
+// one once the tokens it signed have expired. Tokens without a kid (issued
+// before rotation support) are tried against every key.
+type jwtKeyring struct {
+	primary string
+	keys    map[string][]byte
+	order   []string
+}
+
+const defaultJWTKeyID = "default"
+
+func newJWTKeyring(spec string) (*jwtKeyring, error) {
+	kr := &jwtKeyring{keys: make(map[string][]byte)}
+	if strings.TrimSpace(spec) == "" {
+		kr.add(defaultJWTKeyID, []byte(jwtSecretKey))
+		return kr, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("invalid key entry, expected kid:secret")
+		}
+		if _, dup := kr.keys[kid]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kid)
+		}
+		kr.add(kid, []byte(secret))
+	}
+	return kr, nil
+}
+
+func (kr *jwtKeyring) add(kid string, secret []byte) {
+	if kr.primary == "" {
+		kr.primary = kid
+	}
+	kr.keys[kid] = secret
+	kr.order = append(kr.order, kid)
+}
+
+func (kr *jwtKeyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kr.primary
+	return token.SignedString(kr.keys[kr.primary])
+}
+
+func (kr *jwtKeyring) parse(tokenStr string, claims jwt.Claims) error {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenStr, claims)
+	if err != nil {
+		return fmt.Errorf("invalid token")
+	}
+
+	candidates := kr.order
+	if kid, ok := unverified.Header["kid"].(string); ok {
+		if _, known := kr.keys[kid]; !known {
+			return fmt.Errorf("invalid token")
+		}
+		candidates = []string{kid}
+	}
+
+	for _, kid := range candidates {
+		key := kr.keys[kid]
+		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
+			if token.Method != jwt.SigningMethodHS256 {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return key, nil
+		})
+		if err == nil && token.Valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid token")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	maxLoggedBodySize = 64 << 10
+
+	redactedValue = "[REDACTED]"
+)
+
+func newLogger() *slog.Logger {
+	level := slog.LevelInfo
+	var unknown string
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			unknown = v
+			level = slog.LevelInfo
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	if unknown != "" {
+		logger.Warn("unknown LOG_LEVEL, falling back to info", "value", unknown)
+	}
+	return logger
+}
+
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		attrs := []any{"method", r.Method, "path", r.URL.Path, "client_ip", s.trustedProxies.clientIP(r)}
+		if s.logger.Enabled(r.Context(), slog.LevelDebug) && r.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxLoggedBodySize))
+			if err == nil {
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+				attrs = append(attrs, "body", redactBody(body))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+
+		s.logger.Info("request", append(attrs, "duration", time.Since(start))...)
+	})
+}
+
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "<non-json body>"
+	}
+
+	redacted, err := json.Marshal(redactPasswords(v))
+	if err != nil {
+		return "<non-json body>"
+	}
+	return string(redacted)
+}
+
+// redactPasswords replaces password fields and the plaintext of
+// {"$encrypt": ...} values, which json_data holds until it is sealed.
+func redactPasswords(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if k == encryptMarker || strings.Contains(strings.ToLower(k), "password") {
+				val[k] = redactedValue
+				continue
+			}
+			val[k] = redactPasswords(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = redactPasswords(item)
+		}
+	}
+	return v
+}
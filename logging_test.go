@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const loggedPassword = "hunter2-correct-horse"
+
+func TestPasswordIsNotLogged(t *testing.T) {
+	user := User{ID: uuid.New(), Email: "tester@example.com", Password: loggedPassword, Role: testerRole}
+	login := LoginRequest{Email: "tester@example.com", Password: loggedPassword}
+
+	var buf bytes.Buffer
+	for _, h := range []slog.Handler{slog.NewTextHandler(&buf, nil), slog.NewJSONHandler(&buf, nil)} {
+		logger := slog.New(h)
+		logger.Info("user", "user", user, "login", login)
+		logger.Info("pointers", "user", &user, "login", &login)
+	}
+	fmt.Fprintf(&buf, "%v %+v %s\n", user, user, user)
+	fmt.Fprintf(&buf, "%v %+v %s\n", login, login, login)
+	fmt.Fprintf(&buf, "%v %+v\n", []User{user}, map[string]LoginRequest{"login": login})
+
+	if strings.Contains(buf.String(), loggedPassword) {
+		t.Errorf("password appears in output:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "tester@example.com") {
+		t.Errorf("expected the email in output:\n%s", buf.String())
+	}
+}
+
+func TestDebugRequestLogRedactsSecrets(t *testing.T) {
+	s, f := newTestServer(t)
+	var buf bytes.Buffer
+	s.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	f.on("FROM users WHERE lower(email) = $1",
+		[]string{"id", "email", "password", "role", "token_version", "is_active"})
+
+	serve(s, http.MethodPost, "/v1/login", "",
+		`{"email": "tester@example.com", "password": "`+loggedPassword+`", "json_data": {"token": {"$encrypt": "`+loggedPassword+`"}}}`)
+
+	logged := buf.String()
+	if !strings.Contains(logged, "tester@example.com") {
+		t.Fatalf("request body was not logged:\n%s", logged)
+	}
+	if strings.Contains(logged, loggedPassword) {
+		t.Errorf("secret appears in request log:\n%s", logged)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	for _, body := range []string{
+		`{"password": "` + loggedPassword + `"}`,
+		`{"user": {"new_password": "` + loggedPassword + `"}}`,
+		`[{"Password": "` + loggedPassword + `"}]`,
+		`{"json_data": {"$encrypt": "` + loggedPassword + `"}}`,
+	} {
+		if got := redactBody([]byte(body)); strings.Contains(got, loggedPassword) {
+			t.Errorf("redactBody(%s) = %s", body, got)
+		}
+	}
+	if got := redactBody([]byte("not json " + loggedPassword)); got != "<non-json body>" {
+		t.Errorf("redactBody(non-JSON) = %q", got)
+	}
+}
+
+func TestTransactionQueriesAreLogged(t *testing.T) {
+	f, sqlDB := newFakeDB(t)
+	f.on("INSERT INTO projects", nil)
+	f.on("INSERT INTO entities", nil)
+	var buf bytes.Buffer
+	db := &DB{DB: sqlDB, logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+
+	err := withTx(context.Background(), db, func(tx *Tx) error {
+		if _, err := tx.Exec(`INSERT INTO projects (id) VALUES ($1)`, 1); err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(`INSERT INTO entities (id) VALUES ($1)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		_, err = stmt.Exec(2)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"INSERT INTO projects", "INSERT INTO entities"} {
+		if !strings.Contains(buf.String(), query) {
+			t.Errorf("%q was not logged:\n%s", query, buf.String())
+		}
+	}
+}
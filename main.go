@@ -1,1314 +1,2074 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
 )
 
-const (
-	managerRole     = "manager"
-	testAnalystRole = "test-analyst"
-	testerRole      = "tester"
+type User struct {
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Password string    `json:"password"`
+	Role     string    `json:"role"`
+}
 
-	rodikAPI = "http://localhost:8080/api"
-)
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	redacted := user(u)
+	if redacted.Password != "" {
+		redacted.Password = redactedValue
+	}
+	return json.Marshal(redacted)
+}
 
-type Project struct {
-	ID              int       `json:"id"`
-	RodikProjectID  uuid.UUID `json:"rodik_project_id"`
-	Name            string    `json:"name"`
-	Description     string    `json:"description"`
-	ResponsibleName string    `json:"responsible_name"`
-	Status          string    `json:"status"`
-	CompletionDate  *string   `json:"completion_date,omitempty"`
-	IsArchived      bool      `json:"is_archived"`
-	CreatedAt       time.Time `json:"created_at"`
+func (u User) String() string {
+	return fmt.Sprintf("User{ID: %s, Email: %s, Role: %s}", u.ID, u.Email, u.Role)
 }
 
-type Requirement struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", u.ID.String()),
+		slog.String("email", u.Email),
+		slog.String("role", u.Role),
+	)
 }
 
-type RodikRequirement struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"createdAt"`
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
-type RodikTestResult struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
+func (r LoginRequest) String() string {
+	return fmt.Sprintf("LoginRequest{Email: %s}", r.Email)
 }
 
-type TestPlan struct {
-	ID          int       `json:"id"`
-	ProjectID   int       `json:"project_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Goal        string    `json:"goal"`
-	Deadline    *string   `json:"deadline,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+func (r LoginRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", r.Email))
+}
+
+type Claims struct {
+	UserID uuid.UUID `json:"user_id"`
+	// TokenVersion must match users.token_version; bumping the column
+	// revokes every token issued before.
+	TokenVersion int `json:"token_version,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type Project struct {
+	ID                  uuid.UUID `json:"id"`
+	Name                string    `json:"name"`
+	Description         string    `json:"description"`
+	DescriptionFormat   string    `json:"description_format" enum:"plain,markdown"`
+	AllowDuplicateNames bool      `json:"allow_duplicate_test_case_names"`
+	BatchNotifications  bool      `json:"batch_notifications"`
+	IsArchived          bool      `json:"is_archived"`
+	// Metadata holds free-form team fields such as a Jira key or release.
+	Metadata map[string]string `json:"metadata"`
+}
+
+type Entity struct {
+	ID                uuid.UUID       `json:"id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	DescriptionFormat string          `json:"description_format" enum:"plain,markdown"`
+	ProjectID         uuid.UUID       `json:"project_id"`
+	JSONData          json.RawMessage `json:"json_data"`
 }
 
 type TestCase struct {
-	ID          int             `json:"id"`
-	ProjectID   int             `json:"project_id"`
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Status      string          `json:"status"`
-	CreatedAt   time.Time       `json:"created_at"`
-	Data        json.RawMessage `json:"data"`
-}
-
-type TestSuite struct {
-	ID          int       `json:"id"`
-	ProjectID   int       `json:"project_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID                uuid.UUID       `json:"id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	DescriptionFormat string          `json:"description_format" enum:"plain,markdown"`
+	JSONData          json.RawMessage `json:"json_data"`
+	EntityID          uuid.UUID       `json:"entity_id"`
+	ProjectID         uuid.UUID       `json:"project_id"`
+	RequirementID     string          `json:"requirement_id"`
+	AssignedTo        *uuid.UUID      `json:"assigned_to,omitempty"`
+	Priority          string          `json:"priority" enum:"low,medium,high,critical"`
+	Severity          string          `json:"severity" enum:"low,medium,high,critical"`
+	DependsOn         []uuid.UUID     `json:"depends_on"`
+	Tags              []string        `json:"tags"`
+	TimeoutMs         *int64          `json:"timeout_ms,omitempty"`
 }
 
-type TestReport struct {
-	ID          int       `json:"id"`
-	ProjectID   int       `json:"project_id"`
-	TestPlanID  *int      `json:"test_plan_id,omitempty"`
-	TestSuiteID *int      `json:"test_suite_id,omitempty"`
-	PassedTests int       `json:"passed_tests"`
-	Duration    int       `json:"duration"`
-	CreatedAt   time.Time `json:"created_at"`
+const defaultLevel = "medium"
+
+var levels = []string{"low", "medium", "high", "critical"}
+
+func isValidLevel(level string) bool {
+	return slices.Contains(levels, level)
 }
 
-type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+const defaultDescriptionFormat = "plain"
+
+var descriptionFormats = []string{"plain", "markdown"}
+
+func isValidDescriptionFormat(format string) bool {
+	return slices.Contains(descriptionFormats, format)
 }
 
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+type BatchRowError struct {
+	Index      int    `json:"index"`
+	Field      string `json:"field,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+	Error      string `json:"error"`
+
+	conflict bool
 }
 
-type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+type BatchValidationErrors struct {
+	Errors []BatchRowError `json:"errors"`
 }
 
-type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	jwt.StandardClaims
+type BatchUploadResult[T any] struct {
+	Created []T             `json:"created"`
+	Failed  []BatchRowError `json:"failed"`
+}
+
+type RunOptions struct {
+	Label string `json:"label"`
+	// Environment is taken from ?environment= and names the project
+	// environment whose variables fill json_data placeholders.
+	Environment string `json:"-"`
+	// RerunOf is the run whose selection this run repeats.
+	RerunOf uuid.UUID `json:"-"`
+	// SkipPassed (?skip_passed=true) skips cases whose latest result passed.
+	SkipPassed bool `json:"-"`
+}
+
+type TestCaseRunRequest struct {
+	TestCaseIDs []uuid.UUID `json:"test_case_ids"`
+	RunOptions
+}
+
+type TestCaseRunResult struct {
+	TestCaseID   uuid.UUID `json:"test_case_id"`
+	TestCaseName string    `json:"test_case_name"`
+	Status       RunStatus `json:"status" enum:"passed,failed,skipped,blocked,error,cancelled"`
+	RunTime      time.Time `json:"run_time"`
+	DurationMs   int64     `json:"duration_ms"`
+	Reason       string    `json:"reason,omitempty"`
+
+	details json.RawMessage
+}
+
+type TestCaseRunResponse struct {
+	RunID   uuid.UUID  `json:"run_id"`
+	Label   string     `json:"label,omitempty"`
+	Status  string     `json:"status"`
+	RerunOf *uuid.UUID `json:"rerun_of,omitempty"`
+	// SkippedPassed counts the results skipped because of skip_passed.
+	SkippedPassed int                 `json:"skipped_passed,omitempty"`
+	Results       []TestCaseRunResult `json:"results"`
+	Skipped       []uuid.UUID         `json:"skipped"`
+}
+
+type Requirement struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
 }
 
-var (
-	db        *sql.DB
-	jwtSecret = []byte("secret-key")
+const (
+	dbHost     = "localhost"
+	dbPort     = 5432
+	dbUser     = "postgres"
+	dbPassword = "postgres"
+	dbName     = "postgres"
+
+	jwtSecretKey = "super-secret-jwt-key-change-in-production"
 
-	integration = flag.String("int", "", "")
+	managerRole     = "manager"
+	testAnalystRole = "test-analyst"
+	testerRole      = "tester"
 )
 
-func initDB() error {
-	port := "5432"
+// isBypass reports whether r carries the configured BYPASS_KEY. Without a
+// key configured the X-Secret-Key header is ignored.
+func (s *Server) isBypass(r *http.Request) bool {
+	if s.cfg.BypassKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Secret-Key")), []byte(s.cfg.BypassKey)) == 1
+}
 
-	if *integration != "" {
-		port = "5433"
+func (s *Server) authenticate(r *http.Request) (uuid.UUID, string, error) {
+	if s.isBypass(r) {
+		return uuid.Nil, s.cfg.BypassRole, nil
 	}
 
-	connStr := fmt.Sprintf("host=localhost port=%s user=postgres password=postgres dbname=postgres sslmode=disable", port)
-	var err error
-	db, err = sql.Open("postgres", connStr)
+	claims, role, err := s.authenticateToken(r)
 	if err != nil {
-		return err
+		return uuid.Nil, "", err
 	}
-	return db.Ping()
+	return claims.UserID, role, nil
 }
 
-func runMigrations() error {
-	migrationSQL, err := os.ReadFile("migrations.sql")
+// authenticateToken checks the bearer token of r against its signature,
+// expiry, the user's token_version and whether the user is active, and
+// returns its claims with the user's current role.
+func (s *Server) authenticateToken(r *http.Request) (*Claims, string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, "", fmt.Errorf("authorization header required")
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, "", fmt.Errorf("invalid authorization header format")
+	}
+
+	tokenStr := parts[1]
+
+	claims := &Claims{}
+	if err := s.jwtKeys.parse(tokenStr, claims); err != nil {
+		return nil, "", err
+	}
+
+	var role string
+	var tokenVersion int
+	var active bool
+	err := s.db.QueryRowContext(r.Context(), "SELECT role, token_version, is_active FROM users WHERE id = $1", claims.UserID).
+		Scan(&role, &tokenVersion, &active)
+
 	if err != nil {
-		return fmt.Errorf("failed to read migration file: %v", err)
+		if err == sql.ErrNoRows {
+			return nil, "", fmt.Errorf("user not found")
+		}
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+	if !active {
+		return nil, "", errUserDeactivated
 	}
-	_, err = db.Exec(string(migrationSQL))
-	return err
+	if claims.TokenVersion != tokenVersion {
+		return nil, "", fmt.Errorf("token has been revoked")
+	}
+
+	return claims, role, nil
 }
 
-func generateJWT(username, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Username: username,
-		Role:     role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+type TokenInfo struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+	IssuedAt  time.Time `json:"issued_at,omitzero"`
 }
 
-func verifyJWT(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+// validateToken reports whether the bearer token is currently accepted,
+// applying the same revocation and deactivation checks as every other
+// endpoint. The bypass key is not a token and is not accepted here.
+func (s *Server) validateToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, role, err := s.authenticateToken(r)
 	if err != nil {
-		return nil, err
+		writeAuthError(w, err)
+		return
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+
+	info := TokenInfo{UserID: claims.UserID, Role: role}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
 	}
-	return nil, fmt.Errorf("invalid token")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// knownRoles lists the roles from most to least privileged.
+var knownRoles = []string{managerRole, testAnalystRole, testerRole}
+
+func isKnownRole(role string) bool {
+	return slices.Contains(knownRoles, role)
+}
+
+// RoleInfo describes a role. Permissions are checked per handler rather
+// than in a central matrix, so beyond the name only project visibility
+// (see seesAllProjects) is reported.
+type RoleInfo struct {
+	Name            string `json:"name"`
+	SeesAllProjects bool   `json:"sees_all_projects"`
 }
 
-func checkRole(w http.ResponseWriter, r *http.Request, role string) bool {
-	bypass := r.Header.Get("Rodik")
-	if bypass != "" {
-		return true
+func (s *Server) listRoles(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if _, _, err := s.authenticate(r); err != nil {
+		writeAuthError(w, err)
+		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-		return false
+	roles := make([]RoleInfo, 0, len(knownRoles))
+	for _, role := range knownRoles {
+		roles = append(roles, RoleInfo{Name: role, SeesAllProjects: role == managerRole})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+func (s *Server) authenticateAndCheckRole(r *http.Request, requiredRoles ...string) (uuid.UUID, error) {
+	if s.isBypass(r) {
+		if s.cfg.BypassRole != "" && !slices.Contains(requiredRoles, s.cfg.BypassRole) {
+			return uuid.Nil, forbiddenError("bypass key is not allowed for this operation")
+		}
+		return uuid.Nil, nil
 	}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := verifyJWT(tokenString)
+	userID, role, err := s.authenticate(r)
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return false
+		return uuid.Nil, err
 	}
 
-	if claims.Role != role {
-		http.Error(w, "Forbidden: invalid role", http.StatusForbidden)
-		return false
+	if !slices.Contains(requiredRoles, role) {
+		return uuid.Nil, forbiddenError("user role is incorrect")
 	}
 
-	return true
+	return userID, nil
 }
 
-func loginHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		msg := "Invalid request body"
+		if err == errEmptyBody {
+			msg = err.Error()
+		}
+		writeError(w, validationError(msg))
 		return
 	}
 
-	var user User
-	err := db.QueryRow("SELECT id, username, password, name, role, created_at FROM users WHERE username = $1", req.Username).
-		Scan(&user.ID, &user.Username, &user.Password, &user.Name, &user.Role, &user.CreatedAt)
+	email, err := normalizeEmail(req.Email)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	if req.Password != user.Password {
+	var user User
+	var tokenVersion int
+	var active bool
+	err = s.db.QueryRowContext(r.Context(),
+		"SELECT id, email, password, role, token_version, is_active FROM users WHERE lower(email) = $1",
+		email,
+	).Scan(&user.ID, &user.Email, &user.Password, &user.Role, &tokenVersion, &active)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			checkPassword(string(dummyPasswordHash), req.Password)
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	if !checkPassword(user.Password, req.Password) {
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	if !active {
+		writeError(w, forbiddenError("User is deactivated"))
+		return
+	}
+
+	expirationTime := time.Now().Add(s.jwtTTL)
+	claims := &Claims{
+		UserID:       user.ID,
+		TokenVersion: tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
 
-	token, err := generateJWT(user.Username, user.Role)
+	tokenString, err := s.jwtKeys.sign(claims)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	response := LoginResponse{
-		Token: token,
-		User:  user,
-	}
+	response := map[string]any{"token": tokenString}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func getProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var project Project
+	if err := decodeBody(r, &project); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	rows, err := db.Query("SELECT id, name, description, responsible_name, status, completion_date, is_archived, created_at FROM projects")
+	if project.ID == uuid.Nil {
+		project.ID = uuid.New()
+	}
+	if project.DescriptionFormat == "" {
+		project.DescriptionFormat = defaultDescriptionFormat
+	}
+	if !isValidDescriptionFormat(project.DescriptionFormat) {
+		writeError(w, validationError("description_format must be one of "+strings.Join(descriptionFormats, ", ")))
+		return
+	}
+	metadata, err := encodeMetadata(project.Metadata)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, validationError(err.Error()))
 		return
 	}
-	defer rows.Close()
+	if project.Metadata == nil {
+		project.Metadata = map[string]string{}
+	}
 
-	var projects []Project
-	for rows.Next() {
-		var p Project
-		var completionDate sql.NullString
-		err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.ResponsibleName, &p.Status, &completionDate, &p.IsArchived, &p.CreatedAt)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	query := `INSERT INTO projects (` + projectColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = s.db.ExecContext(r.Context(), query, project.ID, project.Name, project.Description, project.DescriptionFormat,
+		project.AllowDuplicateNames, project.BatchNotifications, project.IsArchived, metadata)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+var projectFilterFields = map[string]filterField{
+	"id":          {column: "id", kind: filterUUID},
+	"name":        {column: "name", kind: filterText},
+	"description": {column: "description", kind: filterText},
+}
+
+const projectColumns = `id, name, description, description_format, allow_duplicate_test_case_names, batch_notifications, is_archived, metadata`
+
+func scanProject(row interface{ Scan(...any) error }) (Project, error) {
+	var p Project
+	var description sql.NullString
+	var metadata []byte
+	err := row.Scan(&p.ID, &p.Name, &description, &p.DescriptionFormat, &p.AllowDuplicateNames, &p.BatchNotifications, &p.IsArchived,
+		&metadata)
+	if err != nil {
+		return p, err
+	}
+	p.Description = description.String
+	p.Metadata = map[string]string{}
+	if err := json.Unmarshal(metadata, &p.Metadata); err != nil {
+		return p, fmt.Errorf("metadata of project %s: %w", p.ID, err)
+	}
+	return p, nil
+}
+
+const (
+	maxMetadataKeys     = 50
+	maxMetadataKeyLen   = 100
+	maxMetadataValueLen = 1000
+)
+
+// encodeMetadata validates project metadata and returns it as JSONB input.
+func encodeMetadata(m map[string]string) ([]byte, error) {
+	if len(m) > maxMetadataKeys {
+		return nil, fmt.Errorf("metadata must have at most %d keys", maxMetadataKeys)
+	}
+	for k, v := range m {
+		if k == "" || len(k) > maxMetadataKeyLen {
+			return nil, fmt.Errorf("metadata keys must be 1 to %d characters", maxMetadataKeyLen)
 		}
-		if completionDate.Valid {
-			p.CompletionDate = &completionDate.String
+		if len(v) > maxMetadataValueLen {
+			return nil, fmt.Errorf("metadata.%s must be at most %d characters", k, maxMetadataValueLen)
 		}
-		projects = append(projects, p)
 	}
+	if m == nil {
+		m = map[string]string{}
+	}
+	return json.Marshal(m)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(projects)
+const entityColumns = `id, name, description, description_format, project_id, json_data`
+
+func scanEntity(row interface{ Scan(...any) error }) (Entity, error) {
+	var e Entity
+	var description sql.NullString
+	var jsonData []byte
+	err := row.Scan(&e.ID, &e.Name, &description, &e.DescriptionFormat, &e.ProjectID, &jsonData)
+	e.Description = description.String
+	e.JSONData = jsonData
+	return e, err
+}
+
+type ProjectSettings struct {
+	AllowDuplicateNames *bool `json:"allow_duplicate_test_case_names"`
+	BatchNotifications  *bool `json:"batch_notifications"`
+	// Metadata replaces the project's metadata as a whole.
+	Metadata map[string]string `json:"metadata"`
 }
 
-func getProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) updateProjectSettings(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+	var settings ProjectSettings
+	if err := decodeBody(r, &settings); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	var p Project
-	var completionDate sql.NullString
-	err = db.QueryRow("SELECT id, name, description, responsible_name, status, completion_date, is_archived, created_at FROM projects WHERE id = $1", id).
-		Scan(&p.ID, &p.Name, &p.Description, &p.ResponsibleName, &p.Status, &completionDate, &p.IsArchived, &p.CreatedAt)
-	if err != nil {
-		http.Error(w, "Project not found", http.StatusNotFound)
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if settings.AllowDuplicateNames != nil {
+		set("allow_duplicate_test_case_names", *settings.AllowDuplicateNames)
+	}
+	if settings.BatchNotifications != nil {
+		set("batch_notifications", *settings.BatchNotifications)
+	}
+	if settings.Metadata != nil {
+		metadata, err := encodeMetadata(settings.Metadata)
+		if err != nil {
+			writeError(w, validationError(err.Error()))
+			return
+		}
+		set("metadata", metadata)
+	}
+	if len(sets) == 0 {
+		writeError(w, validationError("No settings to update"))
+		return
+	}
+
+	args = append(args, projectID)
+	project, err := scanProject(s.db.QueryRowContext(r.Context(), fmt.Sprintf(`UPDATE projects SET %s WHERE id = $%d RETURNING `+projectColumns,
+		strings.Join(sets, ", "), len(args)), args...))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Project not found"))
 		return
 	}
-	if completionDate.Valid {
-		p.CompletionDate = &completionDate.String
+	if err != nil {
+		writeDBError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	json.NewEncoder(w).Encode(project)
 }
 
-func createProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+type ProjectArchiveRequest struct {
+	IDs      []uuid.UUID `json:"ids"`
+	Archived *bool       `json:"archived"`
+}
+
+type ProjectArchiveResult struct {
+	Updated []uuid.UUID     `json:"updated"`
+	Failed  []BatchRowError `json:"failed"`
+}
+
+// bulkArchive sets is_archived on the listed projects in one transaction.
+// archived defaults to true; false restores the projects.
+func (s *Server) bulkArchive(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	var p Project
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req ProjectArchiveRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeError(w, validationError("No project IDs provided"))
+		return
+	}
+	if len(req.IDs) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d projects exceeds the maximum of %d", len(req.IDs), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	completionDate := time.Now().Add(2 * 7 * 24 * time.Hour)
+	archived := true
+	if req.Archived != nil {
+		archived = *req.Archived
+	}
 
-	var id int
-	err := db.QueryRow("INSERT INTO projects (name, description, responsible_name, completion_date) VALUES ($1, $2, $3, $4) RETURNING id",
-		p.Name, p.Description, p.ResponsibleName, completionDate).Scan(&id)
+	var updated map[uuid.UUID]bool
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		rows, err := tx.Query(`UPDATE projects SET is_archived = $1 WHERE id = ANY($2) RETURNING id`, archived, pq.Array(req.IDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		updated = make(map[uuid.UUID]bool, len(req.IDs))
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			updated[id] = true
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
+	result := ProjectArchiveResult{Updated: []uuid.UUID{}, Failed: []BatchRowError{}}
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for i, id := range req.IDs {
+		switch {
+		case seen[id]:
+			continue
+		case updated[id]:
+			result.Updated = append(result.Updated, id)
+		default:
+			result.Failed = append(result.Failed, BatchRowError{Index: i, Field: "ids", Error: "project not found"})
+		}
+		seen[id] = true
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(result)
 }
 
-func deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	var where whereClause
+	if err := where.addFilter(r.URL.Query().Get("filter"), projectFilterFields); err != nil {
+		writeError(w, validationError(fmt.Sprintf("Invalid filter: %v", err)))
 		return
 	}
+	addProjectScope(&where, "id", userID, role)
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok || key == "" {
+			continue
+		}
+		for _, v := range values {
+			// Containment (@>) is what the GIN index on metadata serves.
+			filter, _ := json.Marshal(map[string]string{key: v})
+			where.add("metadata @> " + where.arg(string(filter)) + "::jsonb")
+		}
+	}
 
-	id, err := strconv.Atoi(idStr)
+	fields, err := parseFields(r.URL.Query().Get("fields"), projectFields)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, validationError(err.Error()))
 		return
 	}
+	if fields != nil {
+		s.respondSparse(r.Context(), w, s.replica, fields, projectFields,
+			`SELECT `+sparseColumns(fields, projectFields)+` FROM projects`+where.String()+" ORDER BY name", where.args...)
+		return
+	}
+
+	query := `SELECT ` + projectColumns + ` FROM projects`
+	query += where.String() + " ORDER BY name"
 
-	_, err = db.Exec("DELETE FROM projects WHERE id = $1", id)
+	rows, err := s.replica.QueryContext(r.Context(), query, where.args...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+type ProjectBatchGetRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+type ProjectBatchGetResult struct {
+	Projects []Project   `json:"projects"`
+	NotFound []uuid.UUID `json:"not_found"`
 }
 
-func archiveProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+// batchGetProjects returns the listed projects in request order. Projects
+// the caller cannot see are reported as not found.
+func (s *Server) batchGetProjects(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	var req ProjectBatchGetRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeError(w, validationError("No project IDs provided"))
+		return
+	}
+	if len(req.IDs) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d projects exceeds the maximum of %d", len(req.IDs), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	var where whereClause
+	where.add("id = ANY(" + where.arg(pq.Array(req.IDs)) + ")")
+	addProjectScope(&where, "id", userID, role)
+
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+projectColumns+` FROM projects`+where.String(), where.args...)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	defer rows.Close()
 
-	_, err = db.Exec("UPDATE projects SET is_archived = true WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	found := make(map[uuid.UUID]Project, len(req.IDs))
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		found[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	result := ProjectBatchGetResult{Projects: []Project{}, NotFound: []uuid.UUID{}}
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if p, ok := found[id]; ok {
+			result.Projects = append(result.Projects, p)
+		} else {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
-func setProjectCompletionDateHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) addEntity(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	var entity Entity
+	if err := decodeBody(r, &entity); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
-		return
+	if entity.ID == uuid.Nil {
+		entity.ID = uuid.New()
 	}
 
-	var data struct {
-		CompletionDate string `json:"completion_date"`
+	if err := checkJSONDepth(entity.JSONData, s.cfg.MaxJSONDepth); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if entity.DescriptionFormat == "" {
+		entity.DescriptionFormat = defaultDescriptionFormat
 	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !isValidDescriptionFormat(entity.DescriptionFormat) {
+		writeError(w, validationError("description_format must be one of "+strings.Join(descriptionFormats, ", ")))
 		return
 	}
 
-	_, err = db.Exec("UPDATE projects SET completion_date = $1 WHERE id = $2", data.CompletionDate, id)
+	var exists bool
+	err = s.db.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", entity.ProjectID).Scan(&exists)
+	if err != nil || !exists {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	query := `INSERT INTO entities (` + entityColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = s.db.ExecContext(r.Context(), query, entity.ID, entity.Name, entity.Description, entity.DescriptionFormat, entity.ProjectID, entity.JSONData)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
 }
 
-func setProjectDescriptionHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) batchUploadEntities(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	var entities []Entity
+	if err := decodeBody(r, &entities); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+	if len(entities) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d entities exceeds the maximum of %d; split the upload into smaller chunks",
+			len(entities), s.cfg.MaxBatchSize), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	var data struct {
-		Description string `json:"description"`
+	partial := r.URL.Query().Get("mode") == "partial"
+
+	rowErrors, err := s.validateEntities(r.Context(), entities)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if len(rowErrors) > 0 && !partial {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BatchValidationErrors{Errors: rowErrors})
 		return
 	}
 
-	_, err = db.Exec("UPDATE projects SET description = $1 WHERE id = $2", data.Description, id)
+	invalid := make(map[int]bool, len(rowErrors))
+	for _, e := range rowErrors {
+		invalid[e.Index] = true
+	}
+
+	var result BatchUploadResult[Entity]
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		result = BatchUploadResult[Entity]{
+			Created: []Entity{},
+			Failed:  append([]BatchRowError{}, rowErrors...),
+		}
+
+		stmt, err := tx.Prepare(`INSERT INTO entities (` + entityColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i := range entities {
+			if invalid[i] {
+				continue
+			}
+
+			e := &entities[i]
+			if e.ID == uuid.Nil {
+				e.ID = uuid.New()
+			}
+
+			if !partial {
+				if _, err := stmt.Exec(e.ID, e.Name, e.Description, e.DescriptionFormat, e.ProjectID, e.JSONData); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := tx.Exec("SAVEPOINT batch_row"); err != nil {
+				return err
+			}
+
+			if _, err := stmt.Exec(e.ID, e.Name, e.Description, e.DescriptionFormat, e.ProjectID, e.JSONData); err != nil {
+				if isTransientDBError(err) {
+					return err
+				}
+				if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_row"); rbErr != nil {
+					return rbErr
+				}
+				_, msg := dbErrorStatus(err)
+				result.Failed = append(result.Failed, BatchRowError{Index: i, Error: msg})
+				continue
+			}
+
+			if _, err := tx.Exec("RELEASE SAVEPOINT batch_row"); err != nil {
+				return err
+			}
+			result.Created = append(result.Created, *e)
+		}
+		return nil
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	if partial {
+		sort.SliceStable(result.Failed, func(a, b int) bool { return result.Failed[a].Index < result.Failed[b].Index })
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	json.NewEncoder(w).Encode(entities)
+}
+
+func (s *Server) validateEntities(ctx context.Context, entities []Entity) ([]BatchRowError, error) {
+	rowErrors := []BatchRowError{}
+
+	projectIDs := make([]uuid.UUID, 0, len(entities))
+	for _, e := range entities {
+		if e.ProjectID != uuid.Nil {
+			projectIDs = append(projectIDs, e.ProjectID)
+		}
+	}
+
+	known, err := s.idSet(ctx, `SELECT id FROM projects WHERE id = ANY($1)`, projectIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range entities {
+		if strings.TrimSpace(e.Name) == "" {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "name", Error: "name is required"})
+		}
+		if e.DescriptionFormat == "" {
+			entities[i].DescriptionFormat = defaultDescriptionFormat
+		} else if !isValidDescriptionFormat(e.DescriptionFormat) {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "description_format",
+				Error: "description_format must be one of " + strings.Join(descriptionFormats, ", ")})
+		}
+		if err := checkJSONDepth(e.JSONData, s.cfg.MaxJSONDepth); err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "json_data", Error: err.Error()})
+		}
+		if e.ProjectID == uuid.Nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "project_id", Error: "project_id is required"})
+			continue
+		}
+		if !known[e.ProjectID] {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "project_id", Error: "project not found"})
+		}
+	}
+
+	return rowErrors, nil
+}
+
+var entityFilterFields = map[string]filterField{
+	"id":          {column: "id", kind: filterUUID},
+	"name":        {column: "name", kind: filterText},
+	"description": {column: "description", kind: filterText},
+	"project_id":  {column: "project_id", kind: filterUUID},
 }
 
-func getTestCasesHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) listEntities(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	projectIDStr := r.URL.Query().Get("project_id")
-	if projectIDStr == "" {
-		http.Error(w, "project_id parameter is required", http.StatusBadRequest)
+	var where whereClause
+	if err := where.addFilter(r.URL.Query().Get("filter"), entityFilterFields); err != nil {
+		writeError(w, validationError(fmt.Sprintf("Invalid filter: %v", err)))
 		return
 	}
+	addProjectScope(&where, "project_id", userID, role)
+	if v := r.URL.Query().Get("without_testcases"); v != "" {
+		without, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, validationError("without_testcases must be true or false"))
+			return
+		}
+		if without {
+			where.add("NOT EXISTS (SELECT 1 FROM test_cases tc WHERE tc.entity_id = entities.id)")
+		}
+	}
 
-	projectID, err := strconv.Atoi(projectIDStr)
+	fields, err := parseFields(r.URL.Query().Get("fields"), entityFields)
 	if err != nil {
-		http.Error(w, "Invalid project_id", http.StatusBadRequest)
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if fields != nil {
+		s.respondSparse(r.Context(), w, s.replica, fields, entityFields,
+			`SELECT `+sparseColumns(fields, entityFields)+` FROM entities`+where.String()+" ORDER BY name", where.args...)
 		return
 	}
 
-	rows, err := db.Query("SELECT id, project_id, name, description, status, created_at FROM test_cases WHERE project_id = $1", projectID)
+	query := `SELECT ` + entityColumns + ` FROM entities`
+	query += where.String() + " ORDER BY name"
+
+	rows, err := s.replica.QueryContext(r.Context(), query, where.args...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	defer rows.Close()
 
-	var testCases []TestCase
+	entities := []Entity{}
 	for rows.Next() {
-		var tc TestCase
-		err := rows.Scan(&tc.ID, &tc.ProjectID, &tc.Name, &tc.Description, &tc.Status, &tc.CreatedAt)
+		e, err := scanEntity(rows)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
-		testCases = append(testCases, tc)
+		entities = append(entities, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(testCases)
+	json.NewEncoder(w).Encode(entities)
 }
 
-func getTestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+type EntityMoveRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+}
+
+func (s *Server) moveEntity(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	entityID, err := uuid.Parse(ps.ByName("entityId"))
+	if err != nil {
+		writeError(w, validationError("Invalid entity ID"))
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	var req EntityMoveRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	tx, err := begin(r.Context(), s.db, nil)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	defer tx.Rollback()
 
-	var tc TestCase
-	err = db.QueryRow("SELECT id, project_id, name, description, status, created_at FROM test_cases WHERE id = $1", id).
-		Scan(&tc.ID, &tc.ProjectID, &tc.Name, &tc.Description, &tc.Status, &tc.CreatedAt)
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", req.ProjectID).Scan(&exists)
 	if err != nil {
-		http.Error(w, "Test case not found", http.StatusNotFound)
+		writeError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, notFoundError("Project not found"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tc)
-}
+	entity, err := scanEntity(tx.QueryRow(`UPDATE entities SET project_id = $1 WHERE id = $2 RETURNING `+entityColumns,
+		req.ProjectID, entityID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Entity not found"))
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	rows, err := tx.Query(`SELECT id FROM test_cases WHERE entity_id = $1`, entityID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var testCaseIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			writeError(w, err)
+			return
+		}
+		testCaseIDs = append(testCaseIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
 
-func createTestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	before, err := snapshotTestCases(tx, testCaseIDs)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	var tc TestCase
-	if err := json.NewDecoder(r.Body).Decode(&tc); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if _, err := tx.Exec(`UPDATE test_cases SET project_id = $1 WHERE entity_id = $2`, req.ProjectID, entityID); err != nil {
+		writeDBError(w, err)
 		return
 	}
 
-	var id int
-	err := db.QueryRow("INSERT INTO test_cases (project_id, name, description, data) VALUES ($1, $2, $3, $4) RETURNING id",
-		tc.ProjectID, tc.Name, tc.Description, tc.Data).Scan(&id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := recordTestCaseRevisions(tx, before, userID, nil); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(entity)
 }
 
-func createTestCasesHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) batchUploadTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	projectIDStr := r.URL.Query().Get("project_id")
-	if projectIDStr == "" {
-		http.Error(w, "project_id parameter is required", http.StatusBadRequest)
+	var testCases []TestCase
+	if err := decodeBody(r, &testCases); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	projectID, err := strconv.Atoi(projectIDStr)
+	if len(testCases) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d test cases exceeds the maximum of %d; split the upload into smaller chunks",
+			len(testCases), s.cfg.MaxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	partial := r.URL.Query().Get("mode") == "partial"
+
+	for i := range testCases {
+		if testCases[i].Priority == "" {
+			testCases[i].Priority = defaultLevel
+		}
+		if testCases[i].Severity == "" {
+			testCases[i].Severity = defaultLevel
+		}
+		if testCases[i].DescriptionFormat == "" {
+			testCases[i].DescriptionFormat = defaultDescriptionFormat
+		}
+		if testCases[i].ID == uuid.Nil {
+			testCases[i].ID = uuid.New()
+		}
+		if testCases[i].DependsOn == nil {
+			testCases[i].DependsOn = []uuid.UUID{}
+		}
+	}
+
+	rowErrors, err := s.validateTestCases(r.Context(), testCases)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	var tcs []TestCase
-	if err := json.NewDecoder(r.Body).Decode(&tcs); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	sealed := make([]json.RawMessage, len(testCases))
+	for i := range testCases {
+		sealed[i], err = s.sealJSONData(testCases[i].JSONData)
+		if err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "json_data", Error: err.Error()})
+		}
+	}
+
+	if len(rowErrors) > 0 && !partial {
+		status := http.StatusConflict
+		for _, e := range rowErrors {
+			if !e.conflict {
+				status = http.StatusBadRequest
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(BatchValidationErrors{Errors: rowErrors})
 		return
 	}
 
-	var res []int
+	invalid := make(map[int]bool, len(rowErrors))
+	for _, e := range rowErrors {
+		invalid[e.Index] = true
+	}
+
+	var result BatchUploadResult[TestCase]
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		result = BatchUploadResult[TestCase]{
+			Created: []TestCase{},
+			Failed:  append([]BatchRowError{}, rowErrors...),
+		}
+
+		stmt, err := tx.Prepare(`
+			INSERT INTO test_cases (id, name, description, description_format, json_data, entity_id, project_id, requirement_id, priority, severity, depends_on, tags, timeout_ms)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i := range testCases {
+			if invalid[i] {
+				continue
+			}
+
+			tc := &testCases[i]
+
+			if !partial {
+				_, err := stmt.Exec(tc.ID, tc.Name, tc.Description, tc.DescriptionFormat, sealed[i], tc.EntityID, tc.ProjectID, tc.RequirementID, tc.Priority, tc.Severity,
+					pq.Array(tc.DependsOn), pq.Array(tc.Tags), tc.TimeoutMs)
+				if err != nil {
+					return &batchRowDBError{index: i, err: err}
+				}
+				continue
+			}
+
+			if _, err := tx.Exec("SAVEPOINT batch_row"); err != nil {
+				return err
+			}
+
+			_, err := stmt.Exec(tc.ID, tc.Name, tc.Description, tc.DescriptionFormat, sealed[i], tc.EntityID, tc.ProjectID, tc.RequirementID, tc.Priority, tc.Severity,
+				pq.Array(tc.DependsOn), pq.Array(tc.Tags), tc.TimeoutMs)
+			if err != nil {
+				if isTransientDBError(err) {
+					return err
+				}
+				if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_row"); rbErr != nil {
+					return rbErr
+				}
+				result.Failed = append(result.Failed, dbRowError(i, err))
+				continue
+			}
+
+			if _, err := tx.Exec("RELEASE SAVEPOINT batch_row"); err != nil {
+				return err
+			}
+			result.Created = append(result.Created, *tc)
+		}
+		return nil
+	})
+	// The transaction was rolled back; report the row that caused it.
+	var rowErr *batchRowDBError
+	if errors.As(err, &rowErr) {
+		status, _ := dbErrorStatus(rowErr.err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(BatchValidationErrors{Errors: []BatchRowError{dbRowError(rowErr.index, rowErr.err)}})
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if partial {
+		sort.SliceStable(result.Failed, func(a, b int) bool { return result.Failed[a].Index < result.Failed[b].Index })
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	json.NewEncoder(w).Encode(testCases)
+}
+
+func (s *Server) validateTestCases(ctx context.Context, testCases []TestCase) ([]BatchRowError, error) {
+	rowErrors := []BatchRowError{}
+
+	entityIDs := make([]uuid.UUID, 0, len(testCases))
+	for _, tc := range testCases {
+		if tc.EntityID != uuid.Nil {
+			entityIDs = append(entityIDs, tc.EntityID)
+		}
+	}
+
+	entityProjects := make(map[uuid.UUID]uuid.UUID)
+	uniqueNames := make(map[uuid.UUID]bool)
+	if len(entityIDs) > 0 {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT e.id, e.project_id, NOT p.allow_duplicate_test_case_names
+			FROM entities e JOIN projects p ON p.id = e.project_id
+			WHERE e.id = ANY($1)`, pq.Array(entityIDs))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id, projectID uuid.UUID
+			var unique bool
+			if err := rows.Scan(&id, &projectID, &unique); err != nil {
+				return nil, err
+			}
+			entityProjects[id] = projectID
+			uniqueNames[id] = unique
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	existingNames, err := s.existingTestCaseNames(ctx, uniqueNames)
+	if err != nil {
+		return nil, err
+	}
+	batchNames := make(map[testCaseName]int)
 
-	for _, tc := range tcs {
-		var id int
+	for i, tc := range testCases {
+		if !isValidLevel(tc.Priority) {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "priority", Error: "priority must be one of " + strings.Join(levels, ", ")})
+		}
+		if !isValidLevel(tc.Severity) {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "severity", Error: "severity must be one of " + strings.Join(levels, ", ")})
+		}
+		if tc.TimeoutMs != nil && *tc.TimeoutMs <= 0 {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "timeout_ms", Error: "timeout_ms must be positive"})
+		}
+		if !isValidDescriptionFormat(tc.DescriptionFormat) {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "description_format",
+				Error: "description_format must be one of " + strings.Join(descriptionFormats, ", ")})
+		}
+		if tags, err := normalizeTags(tc.Tags); err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "tags", Error: err.Error()})
+		} else {
+			testCases[i].Tags = tags
+		}
+		if strings.TrimSpace(tc.Name) == "" {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "name", Error: "name is required"})
+		}
+		if err := checkJSONDepth(tc.JSONData, s.cfg.MaxJSONDepth); err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "json_data", Error: err.Error()})
+		}
+		if tc.ProjectID == uuid.Nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "project_id", Error: "project_id is required"})
+		}
+		if tc.EntityID == uuid.Nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "entity_id", Error: "entity_id is required"})
+			continue
+		}
 
-		if tc.Name == "" {
-			tc.Name = tc.Description
-			tc.Description = "Description: " + tc.Description
+		projectID, ok := entityProjects[tc.EntityID]
+		if !ok {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "entity_id", Error: "entity not found"})
+			continue
+		}
+		if tc.ProjectID != uuid.Nil && tc.ProjectID != projectID {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "project_id", Error: "project_id does not match the entity's project"})
 		}
 
-		err := db.QueryRow("INSERT INTO test_cases (project_id, name, description data) VALUES ($1, $2, $3, $4) RETURNING id",
-			projectID, tc.Name, tc.Description, tc.Data).Scan(&id)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		if !uniqueNames[tc.EntityID] {
+			continue
+		}
+		key := testCaseName{entityID: tc.EntityID, name: tc.Name}
+		if existingNames[key] {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "name", conflict: true,
+				Error: fmt.Sprintf("a test case named %q already exists in this entity", tc.Name)})
+		} else if first, ok := batchNames[key]; ok {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "name", conflict: true,
+				Error: fmt.Sprintf("test case name %q is also used at index %d", tc.Name, first)})
+		} else {
+			batchNames[key] = i
 		}
+	}
 
-		res = append(res, id)
+	depErrors, err := s.validateDependencies(ctx, testCases)
+	if err != nil {
+		return nil, err
 	}
+	rowErrors = append(rowErrors, depErrors...)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string][]int{"ids": res})
+	return rowErrors, nil
 }
 
-func deleteTestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
+type testCaseName struct {
+	entityID uuid.UUID
+	name     string
+}
+
+func (s *Server) existingTestCaseNames(ctx context.Context, uniqueNames map[uuid.UUID]bool) (map[testCaseName]bool, error) {
+	var entityIDs []uuid.UUID
+	for id, unique := range uniqueNames {
+		if unique {
+			entityIDs = append(entityIDs, id)
+		}
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
-		return
+	names := make(map[testCaseName]bool)
+	if len(entityIDs) == 0 {
+		return names, nil
 	}
 
-	id, err := strconv.Atoi(idStr)
+	rows, err := s.db.QueryContext(ctx, `SELECT entity_id, name FROM test_cases WHERE entity_id = ANY($1)`, pq.Array(entityIDs))
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
-		return
+		return nil, err
 	}
+	defer rows.Close()
 
-	_, err = db.Exec("DELETE FROM test_cases WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	for rows.Next() {
+		var key testCaseName
+		if err := rows.Scan(&key.entityID, &key.name); err != nil {
+			return nil, err
+		}
+		names[key] = true
 	}
+	return names, rows.Err()
+}
 
-	w.WriteHeader(http.StatusNoContent)
+var testCaseFilterFields = map[string]filterField{
+	"id":             {column: "id", kind: filterUUID},
+	"name":           {column: "name", kind: filterText},
+	"description":    {column: "description", kind: filterText},
+	"entity_id":      {column: "entity_id", kind: filterUUID},
+	"project_id":     {column: "project_id", kind: filterUUID},
+	"requirement_id": {column: "requirement_id", kind: filterText},
+	"assigned_to":    {column: "assigned_to", kind: filterUUID},
+	"priority":       {column: "priority", kind: filterText},
+	"severity":       {column: "severity", kind: filterText},
 }
 
-func addRequirementToTestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
-	}
+var testCaseSortColumns = map[string]string{
+	"name":     "name",
+	"priority": "array_position(ARRAY['low', 'medium', 'high', 'critical']::varchar[], priority)",
+	"severity": "array_position(ARRAY['low', 'medium', 'high', 'critical']::varchar[], severity)",
+}
 
-	testCaseIDStr := r.URL.Query().Get("test_case_id")
-	requirementIDStr := r.URL.Query().Get("requirement_id")
-	if testCaseIDStr == "" || requirementIDStr == "" {
-		http.Error(w, "test_case_id and requirement_id parameters are required", http.StatusBadRequest)
-		return
-	}
+const testCaseColumns = `id, name, description, description_format, json_data, entity_id, project_id, requirement_id, assigned_to, priority, severity, depends_on, tags, timeout_ms`
 
-	testCaseID, err := strconv.Atoi(testCaseIDStr)
+func (s *Server) scanTestCase(row interface{ Scan(...any) error }) (TestCase, error) {
+	var tc TestCase
+	var description, requirementID sql.NullString
+	var jsonData []byte
+	var assignedTo uuid.NullUUID
+	var timeoutMs sql.NullInt64
+	err := row.Scan(&tc.ID, &tc.Name, &description, &tc.DescriptionFormat, &jsonData, &tc.EntityID, &tc.ProjectID, &requirementID, &assignedTo,
+		&tc.Priority, &tc.Severity, pq.Array(&tc.DependsOn), pq.Array(&tc.Tags), &timeoutMs)
 	if err != nil {
-		http.Error(w, "Invalid test_case_id", http.StatusBadRequest)
-		return
+		return tc, err
 	}
 
-	requirementID, err := uuid.Parse(requirementIDStr)
-	if err != nil {
-		http.Error(w, "Invalid requirement_id", http.StatusBadRequest)
-		return
+	tc.Description = description.String
+	tc.RequirementID = requirementID.String
+	if assignedTo.Valid {
+		tc.AssignedTo = &assignedTo.UUID
+	}
+	if timeoutMs.Valid {
+		tc.TimeoutMs = &timeoutMs.Int64
 	}
 
-	_, err = db.Exec("INSERT INTO test_case_requirements (test_case_id, requirement_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-		testCaseID, requirementID)
+	tc.JSONData, err = s.openJSONData(jsonData)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return tc, fmt.Errorf("decrypt json_data of test case %s: %w", tc.ID, err)
 	}
-
-	w.WriteHeader(http.StatusNoContent)
+	return tc, nil
 }
 
-func removeRequirementFromTestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+func (s *Server) listTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	testCaseIDStr := r.URL.Query().Get("test_case_id")
-	requirementIDStr := r.URL.Query().Get("requirement_id")
-	if testCaseIDStr == "" || requirementIDStr == "" {
-		http.Error(w, "test_case_id and requirement_id parameters are required", http.StatusBadRequest)
+	var where whereClause
+	if err := where.addFilter(r.URL.Query().Get("filter"), testCaseFilterFields); err != nil {
+		writeError(w, validationError(fmt.Sprintf("Invalid filter: %v", err)))
 		return
 	}
+	addProjectScope(&where, "project_id", userID, role)
 
-	testCaseID, err := strconv.Atoi(testCaseIDStr)
-	if err != nil {
-		http.Error(w, "Invalid test_case_id", http.StatusBadRequest)
-		return
+	switch assignee := r.URL.Query().Get("assigned_to"); assignee {
+	case "":
+	case "me":
+		if userID == uuid.Nil {
+			writeError(w, validationError("assigned_to=me requires a user token"))
+			return
+		}
+		where.add("assigned_to = " + where.arg(userID))
+	default:
+		assigneeID, err := uuid.Parse(assignee)
+		if err != nil {
+			writeError(w, validationError("Invalid assigned_to"))
+			return
+		}
+		where.add("assigned_to = " + where.arg(assigneeID))
 	}
 
-	requirementID, err := uuid.Parse(requirementIDStr)
+	orderBy, err := parseSort(r.URL.Query().Get("sort"), testCaseSortColumns)
 	if err != nil {
-		http.Error(w, "Invalid requirement_id", http.StatusBadRequest)
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM test_case_requirements WHERE test_case_id = $1 AND requirement_id = $2",
-		testCaseID, requirementID)
+	fields, err := parseFields(r.URL.Query().Get("fields"), testCaseFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, validationError(err.Error()))
 		return
 	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func setTestCaseDescriptionHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if fields != nil {
+		s.respondSparse(r.Context(), w, s.replica, fields, testCaseFields,
+			`SELECT `+sparseColumns(fields, testCaseFields)+` FROM test_cases`+where.String()+" ORDER BY "+orderBy, where.args...)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
-		return
-	}
+	query := `SELECT ` + testCaseColumns + ` FROM test_cases`
+	query += where.String() + " ORDER BY " + orderBy
 
-	id, err := strconv.Atoi(idStr)
+	rows, err := s.replica.QueryContext(r.Context(), query, where.args...)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	defer rows.Close()
 
-	var data struct {
-		Description string `json:"description"`
+	testCases := []TestCase{}
+	for rows.Next() {
+		tc, err := s.scanTestCase(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		testCases = append(testCases, tc)
 	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	_, err = db.Exec("UPDATE test_cases SET description = $1 WHERE id = $2", data.Description, id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(testCases)
+}
+
+func (s *Server) assignTestCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole, testAnalystRole)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAuthError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func getTestPlansHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	testCaseID, err := uuid.Parse(ps.ByName("testCaseId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test case ID"))
 		return
 	}
 
-	rows, err := db.Query("SELECT id, project_id, name, description, goal, deadline, created_at FROM test_plans")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var req struct {
+		UserID *uuid.UUID `json:"user_id"`
+	}
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
-	defer rows.Close()
 
-	var testPlans []TestPlan
-	for rows.Next() {
-		var tp TestPlan
-		var deadline sql.NullString
-		err := rows.Scan(&tp.ID, &tp.ProjectID, &tp.Name, &tp.Description, &tp.Goal, &deadline, &tp.CreatedAt)
+	if req.UserID != nil {
+		var role string
+		err := s.db.QueryRowContext(r.Context(), "SELECT role FROM users WHERE id = $1", *req.UserID).Scan(&role)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				writeError(w, notFoundError("User not found"))
+				return
+			}
+			writeError(w, err)
 			return
 		}
-		if deadline.Valid {
-			tp.Deadline = &deadline.String
+		if role != testerRole {
+			writeError(w, validationError("Test cases can only be assigned to testers"))
+			return
 		}
-		testPlans = append(testPlans, tp)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(testPlans)
-}
-
-func getTestPlanHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
-	}
-
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
-		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	tx, err := begin(r.Context(), s.db, nil)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	defer tx.Rollback()
 
-	var tp TestPlan
-	var deadline sql.NullString
-	err = db.QueryRow("SELECT id, project_id, name, description, goal, deadline, created_at FROM test_plans WHERE id = $1", id).
-		Scan(&tp.ID, &tp.ProjectID, &tp.Name, &tp.Description, &tp.Goal, &deadline, &tp.CreatedAt)
+	before, err := snapshotTestCases(tx, []uuid.UUID{testCaseID})
 	if err != nil {
-		http.Error(w, "Test plan not found", http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
-	if deadline.Valid {
-		tp.Deadline = &deadline.String
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tp)
-}
 
-func createTestPlanHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	tc, err := s.scanTestCase(tx.QueryRow(`UPDATE test_cases SET assigned_to = $1 WHERE id = $2 RETURNING `+testCaseColumns,
+		req.UserID, testCaseID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("Test case not found"))
+			return
+		}
+		writeDBError(w, err)
 		return
 	}
 
-	var tp TestPlan
-	if err := json.NewDecoder(r.Body).Decode(&tp); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := recordTestCaseRevisions(tx, before, userID, nil); err != nil {
+		writeError(w, err)
 		return
 	}
-
-	var id int
-	err := db.QueryRow("INSERT INTO test_plans (project_id, name, description, goal, deadline) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		tp.ProjectID, tp.Name, tp.Description, tp.Goal, tp.Deadline).Scan(&id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(tc)
 }
 
-func deleteTestPlanHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
-	}
-
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
-		return
-	}
+type TestCaseBulkUpdate struct {
+	IDs    []uuid.UUID `json:"ids"`
+	Fields struct {
+		Description       *string `json:"description"`
+		DescriptionFormat *string `json:"description_format"`
+		RequirementID     *string `json:"requirement_id"`
+		Priority          *string `json:"priority"`
+		Severity          *string `json:"severity"`
+		TimeoutMs         *int64  `json:"timeout_ms"`
+	} `json:"fields"`
+}
 
-	id, err := strconv.Atoi(idStr)
+func (s *Server) bulkUpdateTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeAuthError(w, err)
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM test_plans WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var req TestCaseBulkUpdate
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func setTestPlanDescriptionHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if len(req.IDs) == 0 {
+		writeError(w, validationError("No test case IDs provided"))
 		return
 	}
-
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	if len(req.IDs) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d test cases exceeds the maximum of %d", len(req.IDs), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
-		return
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
 	}
 
-	var data struct {
-		Description string `json:"description"`
+	f := req.Fields
+	if f.Description != nil {
+		set("description", *f.Description)
 	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if f.DescriptionFormat != nil {
+		if !isValidDescriptionFormat(*f.DescriptionFormat) {
+			writeError(w, validationError("description_format must be one of "+strings.Join(descriptionFormats, ", ")))
+			return
+		}
+		set("description_format", *f.DescriptionFormat)
 	}
-
-	_, err = db.Exec("UPDATE test_plans SET description = $1 WHERE id = $2", data.Description, id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if f.RequirementID != nil {
+		set("requirement_id", *f.RequirementID)
 	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func getTestSuitesHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
+	if f.Priority != nil {
+		if !isValidLevel(*f.Priority) {
+			writeError(w, validationError("priority must be one of "+strings.Join(levels, ", ")))
+			return
+		}
+		set("priority", *f.Priority)
 	}
-
-	rows, err := db.Query("SELECT id, name, description, created_at FROM test_suites")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if f.Severity != nil {
+		if !isValidLevel(*f.Severity) {
+			writeError(w, validationError("severity must be one of "+strings.Join(levels, ", ")))
+			return
+		}
+		set("severity", *f.Severity)
 	}
-	defer rows.Close()
-
-	var testSuites []TestSuite
-	for rows.Next() {
-		var ts TestSuite
-		err := rows.Scan(&ts.ID, &ts.Name, &ts.Description, &ts.CreatedAt)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	if f.TimeoutMs != nil {
+		if *f.TimeoutMs <= 0 {
+			writeError(w, validationError("timeout_ms must be positive"))
 			return
 		}
-		testSuites = append(testSuites, ts)
+		set("timeout_ms", *f.TimeoutMs)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(testSuites)
-}
-
-func getTestSuiteHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if len(sets) == 0 {
+		writeError(w, validationError("No fields to update"))
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	args = append(args, pq.Array(req.IDs))
+	query := fmt.Sprintf(`UPDATE test_cases SET %s WHERE id = ANY($%d) RETURNING id`, strings.Join(sets, ", "), len(args))
+
+	tx, err := begin(r.Context(), s.db, nil)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+	defer tx.Rollback()
 
-	id, err := strconv.Atoi(idStr)
+	before, err := snapshotTestCases(tx, req.IDs)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	var ts TestSuite
-	err = db.QueryRow("SELECT id, name, description, created_at FROM test_suites WHERE id = $1", id).
-		Scan(&ts.ID, &ts.Name, &ts.Description, &ts.CreatedAt)
+	rows, err := tx.Query(query, args...)
 	if err != nil {
-		http.Error(w, "Test suite not found", http.StatusNotFound)
+		writeDBError(w, err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ts)
-}
-
-func createTestSuiteHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	updated := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			writeError(w, err)
+			return
+		}
+		updated = append(updated, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeDBError(w, err)
 		return
 	}
 
-	var ts TestSuite
-	if err := json.NewDecoder(r.Body).Decode(&ts); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := recordTestCaseRevisions(tx, before, userID, nil); err != nil {
+		writeError(w, err)
 		return
 	}
-
-	var id int
-	err := db.QueryRow("INSERT INTO test_suites (project_id, name, description) VALUES ($1, $2, $3) RETURNING id",
-		ts.ProjectID, ts.Name, ts.Description).Scan(&id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(map[string][]uuid.UUID{"updated": updated})
 }
 
-func deleteTestSuiteHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
-	}
-
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.Atoi(idStr)
+func (s *Server) runTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testerRole)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeAuthError(w, err)
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM test_suites WHERE id = $1", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// test_case_ids is decoded entry by entry so a malformed ID is reported
+	// by position instead of failing the whole body.
+	var body struct {
+		TestCaseIDs []json.RawMessage `json:"test_case_ids"`
+		RunOptions
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func addTestCaseToTestSuiteHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if len(body.TestCaseIDs) == 0 {
+		writeError(w, validationError("No test case IDs provided"))
 		return
 	}
-
-	testSuiteIDStr := r.URL.Query().Get("test_suite_id")
-	testCaseIDStr := r.URL.Query().Get("test_case_id")
-	if testSuiteIDStr == "" || testCaseIDStr == "" {
-		http.Error(w, "test_suite_id and test_case_id parameters are required", http.StatusBadRequest)
+	if len(body.TestCaseIDs) > s.cfg.MaxRunCases {
+		http.Error(w, fmt.Sprintf("Request has %d test case IDs, which exceeds the maximum of %d per run",
+			len(body.TestCaseIDs), s.cfg.MaxRunCases), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	testSuiteID, err := strconv.Atoi(testSuiteIDStr)
-	if err != nil {
-		http.Error(w, "Invalid test_suite_id", http.StatusBadRequest)
+	req := TestCaseRunRequest{RunOptions: body.RunOptions}
+	var rowErrors []BatchRowError
+	req.TestCaseIDs, rowErrors = parseTestCaseIDs(body.TestCaseIDs)
+	if len(rowErrors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BatchValidationErrors{Errors: rowErrors})
 		return
 	}
 
-	testCaseID, err := strconv.Atoi(testCaseIDStr)
-	if err != nil {
-		http.Error(w, "Invalid test_case_id", http.StatusBadRequest)
+	if err := req.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
-
-	_, err = db.Exec("INSERT INTO test_case_suites (test_suite_id, test_case_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-		testSuiteID, testCaseID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !s.requireVisibleCases(r.Context(), w, userID, testerRole, req.TestCaseIDs) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	s.respondRun(w, r, req.TestCaseIDs, req.RunOptions)
 }
 
-func removeTestCaseFromTestSuiteHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
+// parseTestCaseIDs parses each entry as a UUID string, dropping repeats
+// while keeping the order of first appearance.
+func parseTestCaseIDs(raw []json.RawMessage) ([]uuid.UUID, []BatchRowError) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := make(map[uuid.UUID]bool, len(raw))
+	var rowErrors []BatchRowError
+	for i, v := range raw {
+		var str string
+		if err := json.Unmarshal(v, &str); err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "test_case_ids", Error: "must be a UUID string"})
+			continue
+		}
+		id, err := uuid.Parse(str)
+		if err != nil {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "test_case_ids", Error: fmt.Sprintf("invalid UUID %q", str)})
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
 	}
+	return ids, rowErrors
+}
 
-	testSuiteIDStr := r.URL.Query().Get("test_suite_id")
-	testCaseIDStr := r.URL.Query().Get("test_case_id")
-	if testSuiteIDStr == "" || testCaseIDStr == "" {
-		http.Error(w, "test_suite_id and test_case_id parameters are required", http.StatusBadRequest)
+func (s *Server) runEntity(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testerRole)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	testSuiteID, err := strconv.Atoi(testSuiteIDStr)
+	entityID, err := uuid.Parse(ps.ByName("entityId"))
 	if err != nil {
-		http.Error(w, "Invalid test_suite_id", http.StatusBadRequest)
+		writeError(w, validationError("Invalid entity ID"))
 		return
 	}
 
-	testCaseID, err := strconv.Atoi(testCaseIDStr)
-	if err != nil {
-		http.Error(w, "Invalid test_case_id", http.StatusBadRequest)
+	var projectID uuid.UUID
+	err = s.db.QueryRowContext(r.Context(), "SELECT project_id FROM entities WHERE id = $1", entityID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Entity not found"))
 		return
 	}
-
-	_, err = db.Exec("DELETE FROM test_case_suites WHERE test_suite_id = $1 AND test_case_id = $2",
-		testSuiteID, testCaseID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func setTestSuiteDescriptionHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	allowed, err := s.canAccessProject(r.Context(), userID, testerRole, projectID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "id parameter is required", http.StatusBadRequest)
+	if !allowed {
+		writeError(w, notFoundError("Entity not found"))
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	opts, err := decodeRunOptions(r)
 	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	var data struct {
-		Description string `json:"description"`
+	testCaseIDs, err := s.queryIDs(r.Context(), `SELECT id FROM test_cases WHERE entity_id = $1 ORDER BY name, id`, entityID)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if len(testCaseIDs) == 0 {
+		writeError(w, validationError("Entity has no test cases"))
 		return
 	}
-
-	_, err = db.Exec("UPDATE test_suites SET description = $1 WHERE id = $2", data.Description, id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if len(testCaseIDs) > s.cfg.MaxRunCases {
+		http.Error(w, fmt.Sprintf("Entity has %d test cases, which exceeds the maximum of %d per run",
+			len(testCaseIDs), s.cfg.MaxRunCases), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	s.respondRun(w, r, testCaseIDs, opts)
 }
 
-func getRequirementsHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
-		return
-	}
-
-	projectIDStr := r.URL.Query().Get("project_id")
-	projectID, err := strconv.Atoi(projectIDStr)
+func (s *Server) runProject(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testerRole)
 	if err != nil {
-		http.Error(w, "Invalid project_id", http.StatusBadRequest)
+		writeAuthError(w, err)
 		return
 	}
 
-	if *integration != "" {
-		var rodikProjectID uuid.UUID
-
-		err := db.QueryRow("SELECT rodik_project_id FROM projects WHERE id = $1", projectID).Scan(&rodikProjectID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		client := &http.Client{}
-
-		req, err := http.NewRequest("GET", rodikAPI+"/requirements?projectId="+rodikProjectID.String(), nil)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		req.Header.Add("Authorization", "Bearer tms")
-		resp, err := client.Do(req)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		var rodikReqs []RodikRequirement
-
-		if err := json.NewDecoder(resp.Body).Decode(&rodikReqs); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		var reqs []Requirement
-		for _, rr := range rodikReqs {
-			reqs = append(reqs, Requirement{
-				ID:          rr.ID,
-				Name:        rr.Title,
-				Description: rr.Description,
-				CreatedAt:   rr.CreatedAt,
-			})
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(reqs)
-
+	projectID, ok := s.projectParam(w, r, ps, userID, testerRole)
+	if !ok {
 		return
 	}
 
-	rows, err := db.Query("SELECT id, name, description, created_at FROM requirements")
+	var exists bool
+	err = s.db.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", projectID).Scan(&exists)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var requirements []Requirement
-	for rows.Next() {
-		var req Requirement
-		err := rows.Scan(&req.ID, &req.Name, &req.Description, &req.CreatedAt)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		requirements = append(requirements, req)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(requirements)
-}
-
-func runTestsHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if !exists {
+		writeError(w, notFoundError("Project not found"))
 		return
 	}
 
-	var data struct {
-		ProjectID   int `json:"project_id"`
-		TestPlanID  int `json:"test_plan_id"`
-		TestSuiteID int `json:"test_suite_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	opts, err := decodeRunOptions(r)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
 		return
 	}
 
-	var id int
-	err := db.QueryRow("INSERT INTO test_reports (project_id, test_plan_id, test_suite_id, passed_percent, duration) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		data.ProjectID, data.TestPlanID, data.TestSuiteID, 100, 30).Scan(&id)
+	testCaseIDs, err := s.queryIDs(r.Context(), `SELECT id FROM test_cases WHERE project_id = $1 ORDER BY name, id`, projectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-
-	if *integration != "" {
+	if len(testCaseIDs) == 0 {
+		writeError(w, validationError("Project has no test cases"))
 		return
 	}
-
-	rows, err := db.Query("SELECT test_case_id FROM test_case_suites WHERE test_suite_id = $1", data.TestSuiteID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if len(testCaseIDs) > s.cfg.MaxRunCases {
+		http.Error(w, fmt.Sprintf("Project has %d test cases, which exceeds the maximum of %d per run",
+			len(testCaseIDs), s.cfg.MaxRunCases), http.StatusRequestEntityTooLarge)
 		return
 	}
-	defer rows.Close()
 
-	var res []RodikTestResult
-	for rows.Next() {
-		var testCaseID int64
-		err := rows.Scan(&testCaseID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		res = append(res, RodikTestResult{
-			ID:     strconv.FormatInt(int64(testCaseID), 10),
-			Status: "PASSED",
-		})
-	}
+	s.respondRun(w, r, testCaseIDs, opts)
+}
 
-	jsonData, err := json.Marshal(res)
+func (s *Server) getRequirements(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeAuthError(w, err)
 		return
 	}
 
-	req, err := http.NewRequest("PATCH", rodikAPI+"/tests", bytes.NewBuffer(jsonData))
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, validationError("Invalid project ID"))
 		return
 	}
+	entityID := ps.ByName("entityId")
 
-	req.Header.Set("Authorization", "Bearer tms")
-
-	client := &http.Client{}
-	_, err = client.Do(req)
+	allowed, err := s.canAccessProject(r.Context(), userID, testAnalystRole, projectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-}
-
-func getTestReportsHandler(w http.ResponseWriter, r *http.Request) {
-	if !checkRole(w, r, managerRole) {
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
 		return
 	}
 
-	rows, err := db.Query("SELECT id, project_id, test_plan_id, test_suite_id, passed_percent, duration, created_at FROM test_reports")
+	limit, offset, err := parsePagination(r, s.cfg.RequirementsPage)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, validationError(err.Error()))
 		return
 	}
-	defer rows.Close()
 
-	var reports []TestReport
-	for rows.Next() {
-		var tr TestReport
-		var testPlanID, testSuiteID sql.NullInt64
-		err := rows.Scan(&tr.ID, &tr.ProjectID, &testPlanID, &testSuiteID, &tr.PassedTests, &tr.Duration, &tr.CreatedAt)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if testPlanID.Valid {
-			val := int(testPlanID.Int64)
-			tr.TestPlanID = &val
-		}
-		if testSuiteID.Valid {
-			val := int(testSuiteID.Int64)
-			tr.TestSuiteID = &val
-		}
-		reports = append(reports, tr)
+	requirements, err := s.requirementList(projectID, entityID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("look up requirements: %v", err), http.StatusBadGateway)
+		return
 	}
 
+	page := pageRequirements(requirements, r.URL.Query().Get("name"), limit, offset)
+	page.setLinks(r)
+	setTotalCount(w, page.Total)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(reports)
+	json.NewEncoder(w).Encode(page)
 }
 
 func main() {
 	flag.Parse()
 
-	if err := initDB(); err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer db.Close()
+	logger := newLogger()
+	slog.SetDefault(logger)
+	cfg := loadConfig()
 
-	if err := runMigrations(); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	db, err := openDB(cfg.DB, logger)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
 	}
+	defer db.Close()
 
-	r := mux.NewRouter()
-
-	r.HandleFunc("/login", loginHandler).Methods("POST")
-
-	r.HandleFunc("/projects", getProjectsHandler).Methods("GET")
-	r.HandleFunc("/project", getProjectHandler).Methods("GET")
-	r.HandleFunc("/project", createProjectHandler).Methods("POST")
-	r.HandleFunc("/project", deleteProjectHandler).Methods("DELETE")
-	r.HandleFunc("/project/archive", archiveProjectHandler).Methods("POST")
-	r.HandleFunc("/project/set-completion-date", setProjectCompletionDateHandler).Methods("POST")
-	r.HandleFunc("/project/set-description", setProjectDescriptionHandler).Methods("POST")
-
-	r.HandleFunc("/test-cases", getTestCasesHandler).Methods("GET")
-	r.HandleFunc("/test-case", getTestCaseHandler).Methods("GET")
-	r.HandleFunc("/test-case", createTestCaseHandler).Methods("POST")
-	r.HandleFunc("/test-cases", createTestCasesHandler).Methods("POST")
-	r.HandleFunc("/test-case", deleteTestCaseHandler).Methods("DELETE")
-	r.HandleFunc("/test-case/add-requirement", addRequirementToTestCaseHandler).Methods("POST")
-	r.HandleFunc("/test-case/remove-requirement", removeRequirementFromTestCaseHandler).Methods("POST")
-	r.HandleFunc("/test-case/set-description", setTestCaseDescriptionHandler).Methods("POST")
+	logger.Info("Database connected successfully")
 
-	r.HandleFunc("/test-plans", getTestPlansHandler).Methods("GET")
-	r.HandleFunc("/test-plan", getTestPlanHandler).Methods("GET")
-	r.HandleFunc("/test-plan", createTestPlanHandler).Methods("POST")
-	r.HandleFunc("/test-plan", deleteTestPlanHandler).Methods("DELETE")
-	r.HandleFunc("/test-plan/set-description", setTestPlanDescriptionHandler).Methods("POST")
+	s, err := NewServer(db, cfg, logger)
+	if err != nil {
+		logger.Error("failed to configure server", "error", err)
+		os.Exit(1)
+	}
 
-	r.HandleFunc("/test-suites", getTestSuitesHandler).Methods("GET")
-	r.HandleFunc("/test-suite", getTestSuiteHandler).Methods("GET")
-	r.HandleFunc("/test-suite", createTestSuiteHandler).Methods("POST")
-	r.HandleFunc("/test-suite", deleteTestSuiteHandler).Methods("DELETE")
-	r.HandleFunc("/test-suite/add-test-case", addTestCaseToTestSuiteHandler).Methods("POST")
-	r.HandleFunc("/test-suite/remove-test-case", removeTestCaseFromTestSuiteHandler).Methods("POST")
-	r.HandleFunc("/test-suite/set-description", setTestSuiteDescriptionHandler).Methods("POST")
+	replica, err := openReplica(cfg.DB, logger)
+	if err != nil {
+		logger.Error("failed to connect to read replica", "error", err)
+		os.Exit(1)
+	}
+	if replica != nil {
+		defer replica.Close()
+		s.replica = replica
+		logger.Info("Read replica connected")
+	}
 
-	r.HandleFunc("/run-tests", runTestsHandler).Methods("POST")
-	r.HandleFunc("/test-reports", getTestReportsHandler).Methods("GET")
+	ctx := context.Background()
+	if *seedFlag {
+		if err := s.seedDatabase(ctx, *forceFlag); err != nil {
+			logger.Error("failed to seed database", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Database seeded successfully")
+		return
+	}
 
-	r.HandleFunc("/requirements", getRequirementsHandler).Methods("GET")
+	if err := s.startScheduler(ctx); err != nil {
+		logger.Error("failed to start scheduler", "error", err)
+		os.Exit(1)
+	}
 
-	log.Println("Server starting on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	logger.Info("Server is running", "port", cfg.Port)
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           s,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+type ProjectMember struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Role      string    `json:"role"`
+}
+
+func seesAllProjects(userID uuid.UUID, role string) bool {
+	return userID == uuid.Nil || role == managerRole
+}
+
+func addProjectScope(where *whereClause, column string, userID uuid.UUID, role string) {
+	if seesAllProjects(userID, role) {
+		return
+	}
+	where.add(fmt.Sprintf("%s IN (SELECT project_id FROM project_members WHERE user_id = %s)", column, where.arg(userID)))
+}
+
+func (s *Server) canAccessProject(ctx context.Context, userID uuid.UUID, role string, projectID uuid.UUID) (bool, error) {
+	if seesAllProjects(userID, role) {
+		return true, nil
+	}
+
+	var member bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM project_members WHERE project_id = $1 AND user_id = $2)`,
+		projectID, userID).Scan(&member)
+	return member, err
+}
+
+func (s *Server) addProjectMember(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+
+	var member ProjectMember
+	if err := decodeBody(r, &member); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	member.ProjectID = projectID
+
+	var userRole string
+	err = s.db.QueryRowContext(r.Context(), "SELECT role FROM users WHERE id = $1", member.UserID).Scan(&userRole)
+	if err != nil {
+		writeError(w, notFoundError("User not found"))
+		return
+	}
+	if member.Role == "" {
+		member.Role = userRole
+	}
+	if !isKnownRole(member.Role) {
+		writeError(w, validationError("Invalid role"))
+		return
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", projectID).Scan(&exists)
+	if err != nil || !exists {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	_, err = s.db.ExecContext(r.Context(), `
+		INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`, member.ProjectID, member.UserID, member.Role)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+func (s *Server) removeProjectMember(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+	userID, err := uuid.Parse(ps.ByName("userId"))
+	if err != nil {
+		writeError(w, validationError("Invalid user ID"))
+		return
+	}
+
+	res, err := s.db.ExecContext(r.Context(), `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`, projectID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Member not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
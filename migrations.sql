@@ -1,81 +1,231 @@
 CREATE TABLE IF NOT EXISTS users (
-    id SERIAL PRIMARY KEY,
-    username VARCHAR(100) UNIQUE NOT NULL,
+    id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
     password VARCHAR(255) NOT NULL,
-    name VARCHAR(200) NOT NULL,
-    role VARCHAR(50) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    role VARCHAR(50) NOT NULL CHECK (role IN ('manager', 'test-analyst', 'tester'))
 );
 
-CREATE TABLE IF NOT EXISTS projects (
-    id SERIAL PRIMARY KEY,
-    rodik_project_id UUID,
-    name VARCHAR(200) NOT NULL,
-    description TEXT,
-    responsible_name VARCHAR(200) NOT NULL,
-    status VARCHAR(50) DEFAULT 'active',
-    completion_date DATE,
-    is_archived BOOLEAN DEFAULT false,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+CREATE TABLE IF NOT EXISTS projects  (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT
 );
 
-CREATE TABLE IF NOT EXISTS requirements (
+CREATE TABLE IF NOT EXISTS entities (
     id UUID PRIMARY KEY,
-    name VARCHAR(200) NOT NULL,
+    name VARCHAR(255) NOT NULL,
     description TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    json_data JSONB
 );
 
-CREATE TABLE IF NOT EXISTS test_plans (
-    id SERIAL PRIMARY KEY,
-    project_id INTEGER,
-    name VARCHAR(200) NOT NULL,
+CREATE TABLE IF NOT EXISTS test_cases (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
     description TEXT,
-    goal TEXT,
-    deadline DATE,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    json_data JSONB,
+    entity_id UUID NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    requirement_id VARCHAR(255)
 );
 
-CREATE TABLE IF NOT EXISTS test_cases (
-    id SERIAL PRIMARY KEY,
-    project_id INTEGER,
-    name VARCHAR(200) NOT NULL,
-    description TEXT,
-    status VARCHAR(50) DEFAULT 'not_run',
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    data JSONB
+CREATE INDEX IF NOT EXISTS idx_entities_project_id ON entities(project_id);
+CREATE INDEX IF NOT EXISTS idx_test_cases_entity_id ON test_cases(entity_id);
+CREATE INDEX IF NOT EXISTS idx_test_cases_project_id ON test_cases(project_id);
+CREATE INDEX IF NOT EXISTS idx_test_cases_requirement_id ON test_cases(requirement_id);
+
+CREATE INDEX IF NOT EXISTS idx_entities_json_data ON entities USING GIN (json_data);
+CREATE INDEX IF NOT EXISTS idx_test_cases_json_data ON test_cases USING GIN (json_data);
+
+CREATE TABLE IF NOT EXISTS test_runs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
-CREATE TABLE IF NOT EXISTS test_case_requirements (
-    test_case_id INTEGER,
-    requirement_id UUID,
-    PRIMARY KEY (test_case_id, requirement_id)
+CREATE TABLE IF NOT EXISTS test_run_results (
+    run_id UUID NOT NULL REFERENCES test_runs(id) ON DELETE CASCADE,
+    test_case_id UUID NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    run_time TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (run_id, test_case_id)
 );
 
-CREATE TABLE IF NOT EXISTS test_suites (
-    id SERIAL PRIMARY KEY,
+CREATE INDEX IF NOT EXISTS idx_test_run_results_test_case_id ON test_run_results(test_case_id);
+
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('manager', 'test-analyst', 'tester')),
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_members_user_id ON project_members(user_id);
+
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS assigned_to UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_test_cases_assigned_to ON test_cases(assigned_to);
+
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS priority VARCHAR(20) NOT NULL DEFAULT 'medium'
+    CHECK (priority IN ('low', 'medium', 'high', 'critical'));
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS severity VARCHAR(20) NOT NULL DEFAULT 'medium'
+    CHECK (severity IN ('low', 'medium', 'high', 'critical'));
+
+CREATE INDEX IF NOT EXISTS idx_test_cases_priority ON test_cases(priority);
+CREATE INDEX IF NOT EXISTS idx_test_cases_severity ON test_cases(severity);
+
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS depends_on UUID[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS label VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_test_runs_label ON test_runs(label);
+
+ALTER TABLE test_run_results ADD COLUMN IF NOT EXISTS details JSONB;
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS allow_duplicate_test_case_names BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS schedules (
+    id UUID PRIMARY KEY,
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE,
+    entity_id UUID REFERENCES entities(id) ON DELETE CASCADE,
+    cron_expr VARCHAR(255) NOT NULL,
+    label VARCHAR(100),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMPTZ,
+    next_run_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((project_id IS NULL) <> (entity_id IS NULL))
+);
+
+ALTER TABLE test_run_results ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS batch_notifications BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Emails are matched case-insensitively from here on. Stop when accounts
+-- differ only in case; merge or rename them by hand, then rerun this file.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(entry, '; ') INTO duplicates FROM (
+        SELECT lower(email) || ' (' || string_agg(id::text, ', ' ORDER BY id) || ')' AS entry
+        FROM users
+        GROUP BY lower(email)
+        HAVING COUNT(*) > 1
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users with emails that differ only in case: %', duplicates
+            USING HINT = 'Merge or rename these accounts, then rerun migrations.sql.';
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+CREATE TABLE IF NOT EXISTS test_case_revisions (
+    test_case_id UUID NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    before JSONB NOT NULL,
+    after JSONB NOT NULL,
+    PRIMARY KEY (test_case_id, revision)
+);
+
+ALTER TABLE test_case_revisions ADD COLUMN IF NOT EXISTS reverted_to INTEGER;
+
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed';
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS finished_at TIMESTAMPTZ;
+
+ALTER TABLE test_run_results DROP CONSTRAINT IF EXISTS test_run_results_status_check;
+ALTER TABLE test_run_results ADD CONSTRAINT test_run_results_status_check
+    CHECK (status IN ('passed', 'failed', 'skipped', 'blocked', 'error', 'cancelled'));
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS description_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (description_format IN ('plain', 'markdown'));
+ALTER TABLE entities ADD COLUMN IF NOT EXISTS description_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (description_format IN ('plain', 'markdown'));
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS description_format VARCHAR(20) NOT NULL DEFAULT 'plain'
+    CHECK (description_format IN ('plain', 'markdown'));
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS is_archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_test_cases_tags ON test_cases USING GIN (tags);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID,
+    action VARCHAR(100) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id TEXT NOT NULL,
+    details JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE test_cases ADD COLUMN IF NOT EXISTS timeout_ms INTEGER CHECK (timeout_ms > 0);
+
+CREATE TABLE IF NOT EXISTS project_environments (
+    id UUID PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    variables JSONB NOT NULL DEFAULT '{}',
+    UNIQUE (project_id, name)
+);
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_projects_metadata ON projects USING GIN (metadata);
+
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS test_case_ids UUID[];
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS environment VARCHAR(100);
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS rerun_of UUID REFERENCES test_runs(id) ON DELETE SET NULL;
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS notification_routes JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE IF NOT EXISTS test_plans (
+    id UUID PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
     name VARCHAR(200) NOT NULL,
-    description TEXT,
-    project_id INTEGER,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    description TEXT NOT NULL DEFAULT '',
+    goal TEXT NOT NULL DEFAULT '',
+    deadline DATE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
-CREATE TABLE IF NOT EXISTS test_case_suites (
-    test_case_id INTEGER,
-    test_suite_id INTEGER,
-    PRIMARY KEY (test_case_id, test_suite_id)
+CREATE INDEX IF NOT EXISTS idx_test_plans_project ON test_plans (project_id);
+
+CREATE TABLE IF NOT EXISTS test_suites (
+    id UUID PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    test_case_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
-CREATE TABLE IF NOT EXISTS test_reports (
-    id SERIAL PRIMARY KEY,
-    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
-    test_plan_id INTEGER REFERENCES test_plans(id) ON DELETE SET NULL,
-    test_suite_id INTEGER REFERENCES test_suites(id) ON DELETE SET NULL,
-    passed_percent INTEGER DEFAULT 0,
-    duration INTEGER,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+CREATE INDEX IF NOT EXISTS idx_test_suites_project ON test_suites (project_id);
+
+-- Passwords used to be stored in plaintext. Hash the remaining ones with
+-- the bcrypt cost the server uses (bcrypt.DefaultCost).
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE users SET password = crypt(password, gen_salt('bf', 10))
+WHERE password !~ '^\$2[aby]\$';
+
+-- Keep this block last. Bump the version here and expectedSchemaVersion in
+-- maintenance.go whenever a migration is added above.
+CREATE TABLE IF NOT EXISTS schema_version (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version INTEGER NOT NULL
 );
 
-INSERT INTO users (username, password, name, role) 
-SELECT 'admin', 'admin123', 'Admin User', 'manager'
-WHERE NOT EXISTS (SELECT 1 FROM users WHERE username = 'admin');
\ No newline at end of file
+INSERT INTO schema_version (version) VALUES (6)
+ON CONFLICT (id) DO UPDATE SET version = EXCLUDED.version;
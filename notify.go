@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+const signatureHeader = "X-Signature"
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+type Notification struct {
+	RequirementID string    `json:"requirement_id"`
+	TestCaseID    uuid.UUID `json:"test_case_id"`
+	Status        RunStatus `json:"status"`
+	SentAt        time.Time `json:"sent_at,omitzero"`
+}
+
+type NotificationBatch struct {
+	RunID     uuid.UUID      `json:"run_id"`
+	ProjectID uuid.UUID      `json:"project_id"`
+	Results   []Notification `json:"results"`
+	SentAt    time.Time      `json:"sent_at"`
+}
+
+// signPayload returns the X-Signature value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of the raw request body keyed with NOTIFY_SECRET.
+// Receivers recompute it over the bytes they received and compare in
+// constant time.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyQueueSize bounds how many runs can wait for their notifications to
+// be delivered. Runs that arrive while it is full are dropped with an error
+// log; their notifications can be sent later with the replay endpoint.
+const notifyQueueSize = 100
+
+type notifyJob struct {
+	runID   uuid.UUID
+	cases   []runnableCase
+	results []TestCaseRunResult
+}
+
+// notifyRun queues the results of a run for delivery and returns without
+// waiting for the receivers, so a slow endpoint cannot hold up the request
+// that finished the run. cases and results are parallel slices.
+func (s *Server) notifyRun(runID uuid.UUID, cases []runnableCase, results []TestCaseRunResult) {
+	if len(cases) == 0 {
+		return
+	}
+
+	select {
+	case s.notifications <- notifyJob{runID: runID, cases: cases, results: results}:
+	default:
+		s.logger.Error("notification queue full, dropping run notifications", "run", runID, "results", len(results))
+	}
+}
+
+// deliverNotifications sends queued runs one at a time until the queue is
+// closed. NewServer starts it.
+func (s *Server) deliverNotifications() {
+	for job := range s.notifications {
+		s.deliverRun(job.runID, job.cases, job.results)
+	}
+}
+
+// deliverRun sends the results of a run. Each result goes where the
+// project's notification routes send its status (see
+// notificationDestination). Projects with batch_notifications get a single
+// NotificationBatch per run and destination; all others get one
+// Notification per test case.
+func (s *Server) deliverRun(runID uuid.UUID, cases []runnableCase, results []TestCaseRunResult) {
+	ctx := context.Background()
+
+	projectIDs := make([]uuid.UUID, 0, len(cases))
+	for _, c := range cases {
+		projectIDs = append(projectIDs, c.projectID)
+	}
+	settings, err := s.notifySettings(ctx, projectIDs)
+	if err != nil {
+		s.logger.Error("load notification settings", "run", runID, "err", err)
+	}
+
+	type batchKey struct {
+		projectID uuid.UUID
+		url       string
+	}
+	batches := make(map[batchKey]*NotificationBatch)
+	var order []batchKey
+	for i, c := range cases {
+		project := settings[c.projectID]
+		if !project.batched {
+			s.sendNotification(project.routes, c.requirementID, c.id, results[i].Status)
+			continue
+		}
+
+		key := batchKey{c.projectID, s.notificationDestination(project.routes, results[i].Status)}
+		if key.url == "" {
+			continue
+		}
+		batch, ok := batches[key]
+		if !ok {
+			batch = &NotificationBatch{RunID: runID, ProjectID: c.projectID}
+			batches[key] = batch
+			order = append(order, key)
+		}
+		batch.Results = append(batch.Results, Notification{
+			RequirementID: c.requirementID,
+			TestCaseID:    c.id,
+			Status:        results[i].Status,
+		})
+	}
+
+	for _, key := range order {
+		batch := batches[key]
+		batch.SentAt = time.Now().UTC()
+		body, err := json.Marshal(batch)
+		if err != nil {
+			s.logger.Error("encode notification batch", "err", err)
+			continue
+		}
+		if err := s.postNotification(key.url, body); err != nil {
+			s.logger.Error("send notification batch", "run", runID, "project", key.projectID, "err", err)
+		}
+	}
+}
+
+// sendNotification posts a single result to the destination routes select
+// for its status.
+func (s *Server) sendNotification(routes []NotificationRoute, requirementID string, testCaseID uuid.UUID, status RunStatus) {
+	url := s.notificationDestination(routes, status)
+	if url == "" {
+		s.logger.Info("notification skipped, no destination for status",
+			"requirement", requirementID, "testcase", testCaseID, "status", status)
+		return
+	}
+
+	body, err := json.Marshal(Notification{
+		RequirementID: requirementID,
+		TestCaseID:    testCaseID,
+		Status:        status,
+		SentAt:        time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Error("encode notification", "err", err)
+		return
+	}
+
+	if err := s.postNotification(url, body); err != nil {
+		s.logger.Error("send notification", "testcase", testCaseID, "err", err)
+	}
+}
+
+func (s *Server) postNotification(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.NotifySecret != "" {
+		req.Header.Set(signatureHeader, signPayload(s.cfg.NotifySecret, body))
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// replayNotifications queues the notifications of a finished run again, for
+// when the receiver was unavailable the first time. Results are replayed
+// with their recorded status; batching and routing follow the current project
+// settings.
+func (s *Server) replayNotifications(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	actorID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	runID, err := uuid.Parse(ps.ByName("runId"))
+	if err != nil {
+		writeError(w, validationError("Invalid run ID"))
+		return
+	}
+	var status string
+	err = s.db.QueryRowContext(r.Context(), `SELECT status FROM test_runs WHERE id = $1`, runID).Scan(&status)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Run not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == runRunning {
+		writeError(w, conflictError("Run is still running"))
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT tc.id, tc.project_id, tc.requirement_id, rr.status
+		FROM test_run_results rr JOIN test_cases tc ON tc.id = rr.test_case_id
+		WHERE rr.run_id = $1
+		ORDER BY rr.run_time, tc.name`, runID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	var cases []runnableCase
+	var results []TestCaseRunResult
+	for rows.Next() {
+		var c runnableCase
+		var requirementID sql.NullString
+		var result TestCaseRunResult
+		if err := rows.Scan(&c.id, &c.projectID, &requirementID, &result.Status); err != nil {
+			writeError(w, err)
+			return
+		}
+		c.requirementID = requirementID.String
+		result.TestCaseID = c.id
+		cases = append(cases, c)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.notifyRun(runID, cases, results)
+
+	err = recordAudit(r.Context(), s.db, actorID, "run.notifications_replayed", "run", runID.String(),
+		map[string]int{"results": len(results)})
+	if err != nil {
+		s.logger.Error("record audit", "run", runID, "err", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"run_id": runID, "replayed": len(results)})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// NotificationRoute sends results with one of Statuses to URL, or drops
+// them when Suppress is set. An empty Statuses matches every status.
+type NotificationRoute struct {
+	Statuses []RunStatus `json:"statuses"`
+	URL      string      `json:"url,omitempty"`
+	Suppress bool        `json:"suppress,omitempty"`
+}
+
+const maxNotificationRoutes = 20
+
+func validateNotificationRoutes(routes []NotificationRoute) error {
+	if len(routes) > maxNotificationRoutes {
+		return fmt.Errorf("at most %d routes are allowed", maxNotificationRoutes)
+	}
+	for i, route := range routes {
+		if route.Statuses == nil {
+			routes[i].Statuses = []RunStatus{}
+		}
+		for _, status := range route.Statuses {
+			if !status.valid() {
+				return fmt.Errorf("routes[%d]: invalid status %q", i, status)
+			}
+		}
+		if route.Suppress {
+			if route.URL != "" {
+				return fmt.Errorf("routes[%d]: url and suppress are mutually exclusive", i)
+			}
+			continue
+		}
+		u, err := url.Parse(route.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("routes[%d]: url must be an absolute http or https URL", i)
+		}
+	}
+	return nil
+}
+
+// notificationDestination returns where a result with status goes: the URL
+// of the first matching route, NOTIFY_URL when no route matches, or "" when
+// it should not be sent.
+func (s *Server) notificationDestination(routes []NotificationRoute, status RunStatus) string {
+	for _, route := range routes {
+		if len(route.Statuses) > 0 && !slices.Contains(route.Statuses, status) {
+			continue
+		}
+		if route.Suppress {
+			return ""
+		}
+		return route.URL
+	}
+	return s.cfg.NotifyURL
+}
+
+type projectNotifySettings struct {
+	batched bool
+	routes  []NotificationRoute
+}
+
+// notifySettings loads batching and routing for each of projectIDs.
+func (s *Server) notifySettings(ctx context.Context, projectIDs []uuid.UUID) (map[uuid.UUID]projectNotifySettings, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, batch_notifications, notification_routes FROM projects WHERE id = ANY($1)`,
+		pq.Array(projectIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[uuid.UUID]projectNotifySettings)
+	for rows.Next() {
+		var id uuid.UUID
+		var ps projectNotifySettings
+		var data []byte
+		if err := rows.Scan(&id, &ps.batched, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &ps.routes); err != nil {
+			return nil, fmt.Errorf("notification routes of project %s: %w", id, err)
+		}
+		settings[id] = ps
+	}
+	return settings, rows.Err()
+}
+
+func (s *Server) loadNotificationRoutes(ctx context.Context, projectID uuid.UUID) ([]NotificationRoute, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT notification_routes FROM projects WHERE id = $1`, projectID).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	routes := []NotificationRoute{}
+	return routes, json.Unmarshal(data, &routes)
+}
+
+func (s *Server) getNotificationRoutes(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+
+	routes, err := s.loadNotificationRoutes(r.Context(), projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routes)
+}
+
+// updateNotificationRoutes replaces the project's routing table. Routes are
+// evaluated in order and the first match wins.
+func (s *Server) updateNotificationRoutes(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+
+	routes := []NotificationRoute{}
+	if err := decodeBody(r, &routes); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if routes == nil {
+		routes = []NotificationRoute{}
+	}
+	if err := validateNotificationRoutes(routes); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	data, err := json.Marshal(routes)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res, err := s.db.ExecContext(r.Context(), `UPDATE projects SET notification_routes = $1 WHERE id = $2`, data, projectID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(routes)
+}
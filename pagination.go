@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type PageLimits struct {
+	Default int
+	Max     int
+}
+
+var defaultPageLimits = PageLimits{Default: 50, Max: 500}
+
+// envPageLimits reads <prefix>_PAGE_DEFAULT and <prefix>_PAGE_MAX so each
+// paginated endpoint can be tuned on its own.
+func envPageLimits(prefix string, def PageLimits) PageLimits {
+	limits := PageLimits{
+		Default: envInt(prefix+"_PAGE_DEFAULT", def.Default),
+		Max:     envInt(prefix+"_PAGE_MAX", def.Max),
+	}
+	if limits.Max < 1 {
+		limits.Max = def.Max
+	}
+	if limits.Default < 1 || limits.Default > limits.Max {
+		limits.Default = min(def.Default, limits.Max)
+	}
+	return limits
+}
+
+type Page[T any] struct {
+	Items  []T       `json:"items"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+	Links  PageLinks `json:"links"`
+}
+
+// PageLinks are request URIs for neighbouring pages. A link is omitted when
+// that page does not exist, e.g. next on the last page, or prev and next
+// when offset is past the end.
+type PageLinks struct {
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// setLinks fills p.Links from r, keeping every query parameter other than
+// limit and offset. Call it once Total, Limit and Offset are set.
+func (p *Page[T]) setLinks(r *http.Request) {
+	link := func(offset int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(p.Limit))
+		q.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = q.Encode()
+		return u.RequestURI()
+	}
+
+	p.Links = PageLinks{First: link(0)}
+	if p.Total == 0 || p.Limit < 1 {
+		return
+	}
+	p.Links.Last = link((p.Total - 1) / p.Limit * p.Limit)
+	if p.Offset >= p.Total {
+		return
+	}
+	if p.Offset > 0 {
+		p.Links.Prev = link(max(p.Offset-p.Limit, 0))
+	}
+	if p.Offset+p.Limit < p.Total {
+		p.Links.Next = link(p.Offset + p.Limit)
+	}
+}
+
+// setTotalCount sets X-Total-Count for clients that paginate by header,
+// and exposes it to cross-origin callers. Only paginated responses set it;
+// total is the count of every matching row, not of the page.
+func setTotalCount(w http.ResponseWriter, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Add("Access-Control-Expose-Headers", "X-Total-Count")
+}
+
+func parsePagination(r *http.Request, limits PageLimits) (limit, offset int, err error) {
+	limit, offset = limits.Default, 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+		if limit > limits.Max {
+			return 0, 0, fmt.Errorf("limit must not exceed %d", limits.Max)
+		}
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+	}
+
+	return limit, offset, nil
+}
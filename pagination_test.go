@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetLinks(t *testing.T) {
+	const base = "/v1/projects/p/runs?"
+	link := func(offset string) string {
+		return base + "limit=10&offset=" + offset + "&status=failed"
+	}
+
+	for _, tc := range []struct {
+		name   string
+		total  int
+		offset int
+		want   PageLinks
+	}{
+		{"empty", 0, 0, PageLinks{First: link("0")}},
+		{"single page", 7, 0, PageLinks{First: link("0"), Last: link("0")}},
+		{"exactly one page", 10, 0, PageLinks{First: link("0"), Last: link("0")}},
+		{"first page", 25, 0, PageLinks{First: link("0"), Next: link("10"), Last: link("20")}},
+		{"middle page", 25, 10, PageLinks{First: link("0"), Prev: link("0"), Next: link("20"), Last: link("20")}},
+		{"last page", 25, 20, PageLinks{First: link("0"), Prev: link("10"), Last: link("20")}},
+		{"last page full", 30, 20, PageLinks{First: link("0"), Prev: link("10"), Last: link("20")}},
+		{"unaligned offset", 25, 5, PageLinks{First: link("0"), Prev: link("0"), Next: link("15"), Last: link("20")}},
+		{"past the end", 25, 40, PageLinks{First: link("0"), Last: link("20")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, base+"status=failed&offset=99&limit=3", nil)
+			p := Page[int]{Total: tc.total, Limit: 10, Offset: tc.offset}
+			p.setLinks(r)
+			if p.Links != tc.want {
+				t.Errorf("links = %+v\nwant %+v", p.Links, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// PasswordPolicy is what new passwords must satisfy. It is served as is by
+// GET /password-policy so clients can check passwords before submitting.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+}
+
+var defaultPasswordPolicy = PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true}
+
+// envPasswordPolicy reads PASSWORD_MIN_LENGTH and PASSWORD_REQUIRE_UPPER,
+// _LOWER, _DIGIT and _SYMBOL.
+func envPasswordPolicy(def PasswordPolicy) PasswordPolicy {
+	p := PasswordPolicy{
+		MinLength:     envInt("PASSWORD_MIN_LENGTH", def.MinLength),
+		RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", def.RequireUpper),
+		RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", def.RequireLower),
+		RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", def.RequireDigit),
+		RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", def.RequireSymbol),
+	}
+	if p.MinLength < 1 {
+		p.MinLength = def.MinLength
+	}
+	return p
+}
+
+// unmet lists the requirements password fails, empty when it passes.
+// Length counts characters, not bytes.
+func (p PasswordPolicy) unmet(password string) []string {
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		}
+	}
+
+	var missing []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		missing = append(missing, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	for _, req := range []struct {
+		required, present bool
+		desc              string
+	}{
+		{p.RequireUpper, upper, "an uppercase letter"},
+		{p.RequireLower, lower, "a lowercase letter"},
+		{p.RequireDigit, digit, "a digit"},
+		{p.RequireSymbol, symbol, "a symbol"},
+	} {
+		if req.required && !req.present {
+			missing = append(missing, req.desc)
+		}
+	}
+	return missing
+}
+
+func (p PasswordPolicy) check(password string) error {
+	if missing := p.unmet(password); len(missing) > 0 {
+		return validationError("password must contain " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (s *Server) passwordPolicy(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.cfg.PasswordPolicy)
+}
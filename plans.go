@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestPlan records what a round of testing on a project is for and when it
+// is due. Deadline is a date, YYYY-MM-DD.
+type TestPlan struct {
+	ID          uuid.UUID `json:"id"`
+	ProjectID   uuid.UUID `json:"project_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Goal        string    `json:"goal"`
+	Deadline    *string   `json:"deadline"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const maxPlanNameLength = 200
+
+func (p *TestPlan) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(p.Name) > maxPlanNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxPlanNameLength)
+	}
+	if p.Deadline != nil {
+		if _, err := time.Parse(time.DateOnly, *p.Deadline); err != nil {
+			return fmt.Errorf("deadline must be a date in YYYY-MM-DD format")
+		}
+	}
+	return nil
+}
+
+const planColumns = `id, project_id, name, description, goal, deadline, created_at`
+
+func scanPlan(row interface{ Scan(...any) error }) (TestPlan, error) {
+	var p TestPlan
+	var deadline sql.NullTime
+	if err := row.Scan(&p.ID, &p.ProjectID, &p.Name, &p.Description, &p.Goal, &deadline, &p.CreatedAt); err != nil {
+		return p, err
+	}
+	if deadline.Valid {
+		d := deadline.Time.Format(time.DateOnly)
+		p.Deadline = &d
+	}
+	return p, nil
+}
+
+func (s *Server) listPlans(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, role)
+	if !ok {
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+planColumns+` FROM test_plans WHERE project_id = $1 ORDER BY created_at, id`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	plans := []TestPlan{}
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		plans = append(plans, p)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plans)
+}
+
+func (s *Server) createPlan(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+
+	var p TestPlan
+	if err := decodeBody(r, &p); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := p.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	p, err = scanPlan(s.db.QueryRowContext(r.Context(), `
+		INSERT INTO test_plans (id, project_id, name, description, goal, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+planColumns, uuid.New(), projectID, p.Name, p.Description, p.Goal, p.Deadline))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (s *Server) updatePlan(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	planID, err := uuid.Parse(ps.ByName("planId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test plan ID"))
+		return
+	}
+
+	var p TestPlan
+	if err := decodeBody(r, &p); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := p.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	p, err = scanPlan(s.db.QueryRowContext(r.Context(), `
+		UPDATE test_plans SET name = $1, description = $2, goal = $3, deadline = $4
+		WHERE id = $5 AND project_id = $6
+		RETURNING `+planColumns, p.Name, p.Description, p.Goal, p.Deadline, planID, projectID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test plan not found"))
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func (s *Server) deletePlan(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	planID, err := uuid.Parse(ps.ByName("planId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test plan ID"))
+		return
+	}
+
+	res, err := s.db.ExecContext(r.Context(), `DELETE FROM test_plans WHERE id = $1 AND project_id = $2`, planID, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Test plan not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+func prettyJSON(next http.Handler, always bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pretty := always
+		if v := r.URL.Query().Get("pretty"); v != "" {
+			pretty, _ = strconv.ParseBool(v)
+		}
+		if !pretty {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r)
+
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		body := bw.buf.Bytes()
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, body, "", "  "); err == nil {
+				body = indented.Bytes()
+			}
+		}
+
+		w.Header().Del("Content-Length")
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+type requirementCacheKey struct {
+	projectID uuid.UUID
+	entityID  string
+}
+
+type requirementCacheEntry struct {
+	requirements []Requirement
+	expires      time.Time
+}
+
+// requirementCache keeps requirement lists per project and entity for ttl
+// so repeated lookups during a testing session skip the requirements
+// system. Expired entries are replaced on the next lookup.
+type requirementCache struct {
+	next    requirementLister
+	ttl     time.Duration
+	entries sync.Map
+}
+
+func newRequirementCache(next requirementLister, ttl time.Duration) *requirementCache {
+	return &requirementCache{next: next, ttl: ttl}
+}
+
+func (c *requirementCache) list(projectID uuid.UUID, entityID string) ([]Requirement, error) {
+	key := requirementCacheKey{projectID: projectID, entityID: entityID}
+	if v, ok := c.entries.Load(key); ok {
+		entry := v.(requirementCacheEntry)
+		if time.Now().Before(entry.expires) {
+			return entry.requirements, nil
+		}
+		c.entries.CompareAndDelete(key, v)
+	}
+
+	requirements, err := c.next(projectID, entityID)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Store(key, requirementCacheEntry{requirements: requirements, expires: time.Now().Add(c.ttl)})
+	return requirements, nil
+}
+
+// invalidate drops the cached lists of a project, or every list when
+// projectID is uuid.Nil, and returns how many were dropped.
+func (c *requirementCache) invalidate(projectID uuid.UUID) int {
+	n := 0
+	c.entries.Range(func(k, _ any) bool {
+		if projectID == uuid.Nil || k.(requirementCacheKey).projectID == projectID {
+			c.entries.Delete(k)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func (s *Server) invalidateRequirementCache(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID := uuid.Nil
+	if v := r.URL.Query().Get("project_id"); v != "" {
+		projectID, err = uuid.Parse(v)
+		if err != nil {
+			writeError(w, validationError("Invalid project ID"))
+			return
+		}
+	}
+
+	if s.requirementCache != nil {
+		n := s.requirementCache.invalidate(projectID)
+		s.logger.Info("requirement cache invalidated", "project_id", projectID, "entries", n)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
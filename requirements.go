@@ -0,0 +1,550 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+const maxRequirementIDLength = 255
+
+// requirementLookup reports which of the given requirement IDs exist in
+// the requirements system.
+type requirementLookup func(ids []string) (map[string]bool, error)
+
+// acceptAllRequirements stands in until the requirements system is
+// integrated (see placeholderRequirements) and treats every ID as known.
+func acceptAllRequirements(ids []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	return known, nil
+}
+
+// pageRequirements filters requirements by a case-insensitive name
+// substring and returns the requested page. The requirements system has no
+// server-side paging yet, so this runs over the full list.
+func pageRequirements(all []Requirement, name string, limit, offset int) Page[Requirement] {
+	page := Page[Requirement]{Items: []Requirement{}, Limit: limit, Offset: offset}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, req := range all {
+		if name != "" && !strings.Contains(strings.ToLower(req.Name), name) {
+			continue
+		}
+		if page.Total >= offset && len(page.Items) < limit {
+			page.Items = append(page.Items, req)
+		}
+		page.Total++
+	}
+	return page
+}
+
+// requirementLister returns the requirements of an entity from the
+// requirements system.
+type requirementLister func(projectID uuid.UUID, entityID string) ([]Requirement, error)
+
+// placeholderRequirements stands in for the requirements system until it is
+// integrated.
+func placeholderRequirements(uuid.UUID, string) ([]Requirement, error) {
+	return []Requirement{
+		{ID: uuid.New(), Name: "Requirement 1"},
+		{ID: uuid.New(), Name: "Requirement 2"},
+		{ID: uuid.New(), Name: "Requirement 3"},
+	}, nil
+}
+
+type RequirementLink struct {
+	TestCaseID    uuid.UUID `json:"test_case_id"`
+	RequirementID string    `json:"requirement_id"`
+}
+
+type RequirementLinkResult struct {
+	Linked []RequirementLink `json:"linked"`
+	Failed []BatchRowError   `json:"failed"`
+}
+
+func (s *Server) bulkLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var links []RequirementLink
+	if err := decodeBody(r, &links); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	if len(links) == 0 {
+		writeError(w, validationError("No links provided"))
+		return
+	}
+	if len(links) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d links exceeds the maximum of %d", len(links), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	partial := r.URL.Query().Get("mode") == "partial"
+
+	rowErrors, err := s.validateLinks(r.Context(), userID, links)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if len(rowErrors) > 0 && !partial {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BatchValidationErrors{Errors: rowErrors})
+		return
+	}
+
+	invalid := make(map[int]bool, len(rowErrors))
+	for _, e := range rowErrors {
+		invalid[e.Index] = true
+	}
+
+	tx, err := begin(r.Context(), s.db, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	result := RequirementLinkResult{
+		Linked: []RequirementLink{},
+		Failed: rowErrors,
+	}
+
+	var linkedIDs []uuid.UUID
+	for i, link := range links {
+		if !invalid[i] {
+			linkedIDs = append(linkedIDs, link.TestCaseID)
+		}
+	}
+	before, err := snapshotTestCases(tx, linkedIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for i, link := range links {
+		if invalid[i] {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE test_cases SET requirement_id = $1 WHERE id = $2`, link.RequirementID, link.TestCaseID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		result.Linked = append(result.Linked, link)
+	}
+
+	if err := recordTestCaseRevisions(tx, before, userID, nil); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// validateLinks reports the links that name an unknown requirement or a
+// test case the caller cannot see; cases outside the caller's projects are
+// reported as not found.
+func (s *Server) validateLinks(ctx context.Context, userID uuid.UUID, links []RequirementLink) ([]BatchRowError, error) {
+	testCaseIDs := make([]uuid.UUID, 0, len(links))
+	requirementIDs := make([]string, 0, len(links))
+	for i := range links {
+		links[i].RequirementID = strings.TrimSpace(links[i].RequirementID)
+		testCaseIDs = append(testCaseIDs, links[i].TestCaseID)
+		if links[i].RequirementID != "" {
+			requirementIDs = append(requirementIDs, links[i].RequirementID)
+		}
+	}
+
+	var where whereClause
+	where.add("id = ANY(" + where.arg(pq.Array(testCaseIDs)) + ")")
+	addProjectScope(&where, "project_id", userID, testAnalystRole)
+	visible, err := s.queryIDs(ctx, `SELECT id FROM test_cases`+where.String(), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[uuid.UUID]bool, len(visible))
+	for _, id := range visible {
+		existing[id] = true
+	}
+
+	known, err := s.requirements(requirementIDs)
+	if err != nil {
+		return nil, fmt.Errorf("look up requirements: %w", err)
+	}
+
+	rowErrors := []BatchRowError{}
+	for i, link := range links {
+		if !existing[link.TestCaseID] {
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "test_case_id", Error: "test case not found"})
+		}
+		switch {
+		case link.RequirementID == "":
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "requirement_id", Error: "requirement_id is required"})
+		case len(link.RequirementID) > maxRequirementIDLength:
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "requirement_id",
+				Error: fmt.Sprintf("requirement_id must be at most %d characters", maxRequirementIDLength)})
+		case !known[link.RequirementID]:
+			rowErrors = append(rowErrors, BatchRowError{Index: i, Field: "requirement_id", Error: "requirement not found"})
+		}
+	}
+
+	sort.SliceStable(rowErrors, func(a, b int) bool { return rowErrors[a].Index < rowErrors[b].Index })
+	return rowErrors, nil
+}
+
+func (s *Server) listUnlinkedTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+
+	var where whereClause
+	if err := where.addFilter(q.Get("filter"), testCaseFilterFields); err != nil {
+		writeError(w, validationError(fmt.Sprintf("Invalid filter: %v", err)))
+		return
+	}
+	addProjectScope(&where, "project_id", userID, role)
+	where.add("COALESCE(TRIM(requirement_id), '') = ''")
+
+	if v := q.Get("project_id"); v != "" {
+		projectID, err := uuid.Parse(v)
+		if err != nil {
+			writeError(w, validationError("Invalid project ID"))
+			return
+		}
+		where.add("project_id = " + where.arg(projectID))
+	}
+
+	orderBy, err := parseSort(q.Get("sort"), testCaseSortColumns)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.TestCasesPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	page := Page[TestCase]{Items: []TestCase{}, Limit: limit, Offset: offset}
+	if err := s.replica.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM test_cases`+where.String(), where.args...).Scan(&page.Total); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	args := append(where.args, limit, offset)
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+testCaseColumns+` FROM test_cases`+where.String()+" ORDER BY "+orderBy+
+		fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tc, err := s.scanTestCase(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		page.Items = append(page.Items, tc)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	page.setLinks(r)
+	setTotalCount(w, page.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+type CoveredTestCase struct {
+	TestCaseID uuid.UUID  `json:"test_case_id"`
+	Name       string     `json:"name"`
+	ProjectID  uuid.UUID  `json:"project_id"`
+	Status     string     `json:"status"`
+	RunTime    *time.Time `json:"run_time,omitempty"`
+}
+
+type RequirementCoverage struct {
+	RequirementID string            `json:"requirement_id"`
+	Total         int               `json:"total"`
+	Counts        map[string]int    `json:"counts"`
+	TestCases     []CoveredTestCase `json:"test_cases"`
+}
+
+// reqCoverage reports the test cases linked to a requirement with the
+// status of their latest run; cases that never ran count as "not_run".
+func (s *Server) reqCoverage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	requirementID := strings.TrimSpace(ps.ByName("requirementId"))
+	if requirementID == "" || len(requirementID) > maxRequirementIDLength {
+		writeError(w, validationError("Invalid requirement ID"))
+		return
+	}
+
+	known, err := s.requirements([]string{requirementID})
+	if err != nil {
+		writeError(w, fmt.Errorf("look up requirements: %w", err))
+		return
+	}
+	if !known[requirementID] {
+		writeError(w, notFoundError("Requirement not found"))
+		return
+	}
+
+	var where whereClause
+	where.add("tc.requirement_id = " + where.arg(requirementID))
+	addProjectScope(&where, "tc.project_id", userID, role)
+
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT tc.id, tc.name, tc.project_id, latest.status, latest.run_time
+		FROM test_cases tc
+		LEFT JOIN LATERAL (
+			SELECT status, run_time FROM test_run_results
+			WHERE test_case_id = tc.id
+			ORDER BY run_time DESC
+			LIMIT 1
+		) latest ON true`+where.String()+`
+		ORDER BY tc.name`, where.args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	coverage := RequirementCoverage{
+		RequirementID: requirementID,
+		Counts:        map[string]int{},
+		TestCases:     []CoveredTestCase{},
+	}
+	for rows.Next() {
+		var tc CoveredTestCase
+		var status sql.NullString
+		var runTime sql.NullTime
+		if err := rows.Scan(&tc.TestCaseID, &tc.Name, &tc.ProjectID, &status, &runTime); err != nil {
+			writeError(w, err)
+			return
+		}
+		tc.Status = "not_run"
+		if status.Valid {
+			tc.Status = status.String
+		}
+		if runTime.Valid {
+			tc.RunTime = &runTime.Time
+		}
+		coverage.Counts[tc.Status]++
+		coverage.TestCases = append(coverage.TestCases, tc)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	coverage.Total = len(coverage.TestCases)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(coverage)
+}
+
+type RequirementRemapRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// ProjectID limits the remap to one project; by default every linked
+	// test case in a project the caller can access moves.
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+}
+
+type RequirementRemapResult struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Updated int    `json:"updated"`
+}
+
+// remapRequirement moves every test case linked to one requirement to
+// another in a single transaction, for when requirements are merged or
+// renamed upstream. The old requirement need not exist any more; the new
+// one must.
+func (s *Server) remapRequirement(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var req RequirementRemapRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	req.From = strings.TrimSpace(req.From)
+	req.To = strings.TrimSpace(req.To)
+	for _, f := range []struct{ name, value string }{{"from", req.From}, {"to", req.To}} {
+		if f.value == "" || len(f.value) > maxRequirementIDLength {
+			writeError(w, validationError(fmt.Sprintf("%s must be 1 to %d characters", f.name, maxRequirementIDLength)))
+			return
+		}
+	}
+	if req.From == req.To {
+		writeError(w, validationError("from and to must differ"))
+		return
+	}
+
+	known, err := s.requirements([]string{req.To})
+	if err != nil {
+		writeError(w, fmt.Errorf("look up requirements: %w", err))
+		return
+	}
+	if !known[req.To] {
+		writeError(w, notFoundError("Requirement not found"))
+		return
+	}
+
+	var where whereClause
+	where.add("requirement_id = " + where.arg(req.From))
+	if req.ProjectID != nil {
+		allowed, err := s.canAccessProject(r.Context(), userID, testAnalystRole, *req.ProjectID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !allowed {
+			writeError(w, notFoundError("Project not found"))
+			return
+		}
+		where.add("project_id = " + where.arg(*req.ProjectID))
+	}
+	addProjectScope(&where, "project_id", userID, testAnalystRole)
+
+	result := RequirementRemapResult{From: req.From, To: req.To}
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		rows, err := tx.Query(`SELECT id FROM test_cases`+where.String()+` FOR UPDATE`, where.args...)
+		if err != nil {
+			return err
+		}
+		var ids []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		before, err := snapshotTestCases(tx, ids)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`UPDATE test_cases SET requirement_id = $1 WHERE id = ANY($2)`, req.To, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		result.Updated = int(n)
+
+		if err := recordTestCaseRevisions(tx, before, userID, nil); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), tx, userID, "requirement.remapped", "requirement", req.From, result)
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+type RequirementUsage struct {
+	RequirementID string `json:"requirement_id"`
+	TestCases     int    `json:"test_cases"`
+}
+
+// distinctReqs lists the requirement IDs linked from a project's test
+// cases with how many cases link each, straight from the database and
+// without asking the requirements system.
+func (s *Server) distinctReqs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, role)
+	if !ok {
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT requirement_id, COUNT(*)
+		FROM test_cases
+		WHERE project_id = $1 AND COALESCE(TRIM(requirement_id), '') <> ''
+		GROUP BY requirement_id
+		ORDER BY requirement_id`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	usage := []RequirementUsage{}
+	for rows.Next() {
+		var u RequirementUsage
+		if err := rows.Scan(&u.RequirementID, &u.TestCases); err != nil {
+			writeError(w, err)
+			return
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
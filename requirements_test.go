@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRemapRequirementScope(t *testing.T) {
+	t.Run("inaccessible project", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testAnalystRole)
+		f.on("SELECT EXISTS(SELECT 1 FROM project_members", []string{"exists"}, []driver.Value{false})
+
+		rec := serve(s, http.MethodPost, "/v1/requirements/remap", token,
+			`{"from": "REQ-1", "to": "REQ-2", "project_id": "`+uuid.NewString()+`"}`)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+		if n := len(f.executed("UPDATE test_cases")); n != 0 {
+			t.Errorf("%d updates, want 0", n)
+		}
+	})
+
+	t.Run("without project limited to member projects", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testAnalystRole)
+		f.on("SELECT id FROM test_cases WHERE requirement_id = $1 AND project_id IN (SELECT project_id FROM project_members WHERE user_id = $2) FOR UPDATE",
+			[]string{"id"})
+		f.on("UPDATE test_cases", nil)
+		f.on("INSERT INTO audit_log", nil)
+
+		rec := serve(s, http.MethodPost, "/v1/requirements/remap", token, `{"from": "REQ-1", "to": "REQ-2"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestBulkLinkHidesCasesOutsideCallerProjects(t *testing.T) {
+	hidden := uuid.New()
+	s, f := newTestServer(t)
+	token := tokenFor(t, s, f, testAnalystRole)
+	f.on("SELECT id FROM test_cases WHERE id = ANY($1) AND project_id IN (SELECT project_id FROM project_members WHERE user_id = $2)",
+		[]string{"id"})
+
+	rec := serve(s, http.MethodPost, "/v1/testcases/link-requirements", token,
+		`[{"test_case_id": "`+hidden.String()+`", "requirement_id": "REQ-1"}]`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var resp BatchValidationErrors
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Field != "test_case_id" {
+		t.Errorf("errors = %+v, want one test_case_id error", resp.Errors)
+	}
+	if n := len(f.executed("UPDATE test_cases")); n != 0 {
+		t.Errorf("%d updates, want 0", n)
+	}
+}
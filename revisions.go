@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// snapshotTestCases captures the stored rows of the given test cases so
+// recordTestCaseRevisions can compare them after an update in the same
+// transaction.
+func snapshotTestCases(tx *Tx, ids []uuid.UUID) (map[uuid.UUID][]byte, error) {
+	if len(ids) == 0 {
+		return map[uuid.UUID][]byte{}, nil
+	}
+
+	rows, err := tx.Query(`SELECT id, to_jsonb(tc) FROM test_cases tc WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[uuid.UUID][]byte, len(ids))
+	for rows.Next() {
+		var id uuid.UUID
+		var row []byte
+		if err := rows.Scan(&id, &row); err != nil {
+			return nil, err
+		}
+		snapshot[id] = row
+	}
+	return snapshot, rows.Err()
+}
+
+// recordTestCaseRevisions stores a revision for every test case in before
+// whose row changed. changedBy is uuid.Nil for bypass requests; revertedTo
+// is set when the change restores an earlier version.
+func recordTestCaseRevisions(tx *Tx, before map[uuid.UUID][]byte, changedBy uuid.UUID, revertedTo *int) error {
+	if len(before) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	after, err := snapshotTestCases(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	var author uuid.NullUUID
+	if changedBy != uuid.Nil {
+		author = uuid.NullUUID{UUID: changedBy, Valid: true}
+	}
+
+	for _, id := range ids {
+		prev, next := before[id], after[id]
+		if next == nil || bytes.Equal(prev, next) {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO test_case_revisions (test_case_id, revision, changed_by, before, after, reverted_to)
+			SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5 FROM test_case_revisions WHERE test_case_id = $1`,
+			id, author, prev, next, revertedTo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type TestCaseRevision struct {
+	Revision   int           `json:"revision"`
+	ChangedBy  *uuid.UUID    `json:"changed_by"`
+	ChangedAt  time.Time     `json:"changed_at"`
+	RevertedTo *int          `json:"reverted_to,omitempty"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+func diffRows(before, after []byte) ([]FieldChange, error) {
+	var prev, next map[string]json.RawMessage
+	if err := json.Unmarshal(before, &prev); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &next); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(prev)+len(next))
+	for k := range prev {
+		fields[k] = true
+	}
+	for k := range next {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if bytes.Equal(prev[name], next[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Before: nullIfEmpty(prev[name]), After: nullIfEmpty(next[name])})
+	}
+	return changes, nil
+}
+
+func nullIfEmpty(v json.RawMessage) json.RawMessage {
+	if len(v) == 0 {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+func (s *Server) testCaseHistory(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	testCaseID, err := uuid.Parse(ps.ByName("testCaseId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test case ID"))
+		return
+	}
+
+	var projectID uuid.UUID
+	err = s.replica.QueryRowContext(r.Context(), `SELECT project_id FROM test_cases WHERE id = $1`, testCaseID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT revision, changed_by, changed_at, reverted_to, before, after
+		FROM test_case_revisions WHERE test_case_id = $1
+		ORDER BY revision DESC`, testCaseID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	history := []TestCaseRevision{}
+	for rows.Next() {
+		var rev TestCaseRevision
+		var changedBy uuid.NullUUID
+		var revertedTo sql.NullInt32
+		var before, after []byte
+		if err := rows.Scan(&rev.Revision, &changedBy, &rev.ChangedAt, &revertedTo, &before, &after); err != nil {
+			writeError(w, err)
+			return
+		}
+		if changedBy.Valid {
+			rev.ChangedBy = &changedBy.UUID
+		}
+		if revertedTo.Valid {
+			v := int(revertedTo.Int32)
+			rev.RevertedTo = &v
+		}
+		rev.Changes, err = diffRows(before, after)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		history = append(history, rev)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// revertTestCase restores version n of a test case as a new revision.
+// Version n is the row as it was after revision n; version 0 is the row
+// before its first recorded change.
+func (s *Server) revertTestCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err == nil && role != "" && role != managerRole && role != testAnalystRole {
+		err = fmt.Errorf("user role is incorrect")
+	}
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	testCaseID, err := uuid.Parse(ps.ByName("testCaseId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test case ID"))
+		return
+	}
+	version, err := strconv.Atoi(ps.ByName("version"))
+	if err != nil || version < 0 {
+		writeError(w, validationError("Invalid version"))
+		return
+	}
+
+	var projectID uuid.UUID
+	err = s.db.QueryRowContext(r.Context(), `SELECT project_id FROM test_cases WHERE id = $1`, testCaseID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+
+	tx, err := begin(r.Context(), s.db, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var snapshot []byte
+	if version == 0 {
+		err = tx.QueryRow(`SELECT before FROM test_case_revisions WHERE test_case_id = $1 AND revision = 1`, testCaseID).Scan(&snapshot)
+	} else {
+		err = tx.QueryRow(`SELECT after FROM test_case_revisions WHERE test_case_id = $1 AND revision = $2`, testCaseID, version).Scan(&snapshot)
+	}
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Version not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	before, err := snapshotTestCases(tx, []uuid.UUID{testCaseID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Columns missing from older snapshots keep their current value.
+	tc, err := s.scanTestCase(tx.QueryRow(`
+		UPDATE test_cases tc SET
+			(name, description, json_data, entity_id, project_id, requirement_id, assigned_to, priority, severity, depends_on) =
+			(SELECT v.name, v.description, v.json_data, v.entity_id, v.project_id, v.requirement_id, v.assigned_to,
+				v.priority, v.severity, v.depends_on
+			FROM jsonb_populate_record(tc, $1) v)
+		WHERE tc.id = $2
+		RETURNING `+testCaseColumns, snapshot, testCaseID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if err := recordTestCaseRevisions(tx, before, userID, &version); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tc)
+}
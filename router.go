@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Router keeps httprouter-style registration and handler signatures but
+// dispatches through http.ServeMux, which unlike httprouter lets static
+// segments such as /testcases/batch coexist with /testcases/:testCaseId/...
+type Router struct {
+	mux    *http.ServeMux
+	prefix string
+	// timeout bounds each handler registered through this router; zero
+	// means no limit.
+	timeout time.Duration
+	// writeTimeout is the server's HTTP_WRITE_TIMEOUT. Routes with a longer
+	// timeout move their write deadline out so the server does not close
+	// the connection before the handler is done.
+	writeTimeout time.Duration
+}
+
+// newRouter registers every route under prefix, which is either empty or a
+// normalized base path such as "/api/v1".
+func newRouter(prefix string, writeTimeout time.Duration) *Router {
+	return &Router{mux: http.NewServeMux(), prefix: prefix, writeTimeout: writeTimeout}
+}
+
+func (rt *Router) Group(prefix string) *Router {
+	g := *rt
+	g.prefix += prefix
+	return &g
+}
+
+// WithTimeout returns a router registering on the same mux and prefix whose
+// handlers answer 503 once they run longer than d. The request context is
+// cancelled at the same moment, which aborts the handler's queries. The
+// response is buffered until the handler returns, so streaming endpoints
+// must be registered on a router without a timeout.
+func (rt *Router) WithTimeout(d time.Duration) *Router {
+	g := *rt
+	g.timeout = d
+	return &g
+}
+
+const currentAPIVersion = "/v1"
+
+var apiVersions = []string{"/v1"}
+
+// routePath strips the base path and API version from an incoming path so
+// middleware can recognise a route however it was reached.
+func routePath(path, basePath string) string {
+	path = strings.TrimPrefix(path, basePath)
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, v); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return path
+}
+
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+func (rt *Router) Handle(method, path string, handle httprouter.Handle) {
+	segments := strings.Split(rt.prefix+path, "/")
+	var names []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			names = append(names, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps := make(httprouter.Params, 0, len(names))
+		for _, name := range names {
+			ps = append(ps, httprouter.Param{Key: name, Value: r.PathValue(name)})
+		}
+		handle(w, r, ps)
+	})
+	if rt.timeout > 0 {
+		h = http.TimeoutHandler(h, rt.timeout, "Request timed out")
+	}
+	if rt.writeTimeout > 0 && rt.timeout > rt.writeTimeout {
+		h = extendWriteDeadline(h, rt.timeout+rt.writeTimeout)
+	}
+	rt.mux.Handle(method+" "+strings.Join(segments, "/"), h)
+}
+
+// extendWriteDeadline gives a route d to run and write its response,
+// replacing the server-wide write deadline. Writers that cannot change
+// their deadline, such as test recorders, keep the server's.
+func extendWriteDeadline(next http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rt *Router) GET(path string, handle httprouter.Handle) {
+	rt.Handle(http.MethodGet, path, handle)
+}
+
+func (rt *Router) POST(path string, handle httprouter.Handle) {
+	rt.Handle(http.MethodPost, path, handle)
+}
+
+func (rt *Router) PUT(path string, handle httprouter.Handle) {
+	rt.Handle(http.MethodPut, path, handle)
+}
+
+func (rt *Router) PATCH(path string, handle httprouter.Handle) {
+	rt.Handle(http.MethodPatch, path, handle)
+}
+
+func (rt *Router) DELETE(path string, handle httprouter.Handle) {
+	rt.Handle(http.MethodDelete, path, handle)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
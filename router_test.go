@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestRouteTimeoutCancelsRequestContext(t *testing.T) {
+	rt := newRouter("", 0)
+	cancelled := make(chan error, 1)
+	rt.WithTimeout(20*time.Millisecond).GET("/slow", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+		case <-time.After(time.Second):
+			cancelled <- nil
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if err := <-cancelled; err == nil {
+		t.Error("handler context was not cancelled at the timeout")
+	}
+}
+
+func TestLongRouteOutlivesServerWriteTimeout(t *testing.T) {
+	const writeTimeout = 50 * time.Millisecond
+	rt := newRouter("", writeTimeout)
+	slow := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		time.Sleep(3 * writeTimeout)
+		io.WriteString(w, "done")
+	}
+	rt.WithTimeout(time.Second).GET("/long", slow)
+	rt.WithTimeout(time.Second/2).Group("/v1").GET("/long", slow)
+
+	srv := httptest.NewUnstartedServer(prettyJSON(rt, false))
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	for _, path := range []string{"/long", "/v1/long?pretty=true"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != "done" {
+			t.Errorf("GET %s = %q, %v; want done", path, body, err)
+		}
+	}
+}
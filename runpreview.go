@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// RunPreviewRequest selects cases the way the run endpoints do: explicit
+// test_case_ids, or every case of one entity or project.
+type RunPreviewRequest struct {
+	TestCaseIDs []uuid.UUID `json:"test_case_ids"`
+	EntityID    *uuid.UUID  `json:"entity_id"`
+	ProjectID   *uuid.UUID  `json:"project_id"`
+}
+
+const (
+	previewRun   = "run"
+	previewSkip  = "skip"
+	previewError = "error"
+)
+
+// RunPreviewCase is one case in execution order. SkippedIfFailed lists the
+// dependencies within the run; if any of them does not pass, the case is
+// skipped at run time.
+type RunPreviewCase struct {
+	TestCaseID      uuid.UUID   `json:"test_case_id"`
+	TestCaseName    string      `json:"test_case_name"`
+	Action          string      `json:"action" enum:"run,skip,error"`
+	Reason          string      `json:"reason,omitempty"`
+	SkippedIfFailed []uuid.UUID `json:"skipped_if_failed"`
+}
+
+type RunPreview struct {
+	Cases    []RunPreviewCase `json:"cases"`
+	NotFound []uuid.UUID      `json:"not_found"`
+}
+
+func (s *Server) previewTestCaseIDs(ctx context.Context, req RunPreviewRequest) ([]uuid.UUID, error) {
+	switch {
+	case len(req.TestCaseIDs) > 0 && req.EntityID == nil && req.ProjectID == nil:
+		return req.TestCaseIDs, nil
+	case len(req.TestCaseIDs) == 0 && req.EntityID != nil && req.ProjectID == nil:
+		return s.queryIDs(ctx, `SELECT id FROM test_cases WHERE entity_id = $1 ORDER BY name, id`, *req.EntityID)
+	case len(req.TestCaseIDs) == 0 && req.EntityID == nil && req.ProjectID != nil:
+		return s.queryIDs(ctx, `SELECT id FROM test_cases WHERE project_id = $1 ORDER BY name, id`, *req.ProjectID)
+	}
+	return nil, errInvalidSelection
+}
+
+var errInvalidSelection = validationError("exactly one of test_case_ids, entity_id or project_id is required")
+
+// runPreview resolves a run selection without executing anything. Cases
+// that would fail before executing (for example an undefined environment
+// variable) are reported as errors and everything depending on them as
+// skipped.
+func (s *Server) runPreview(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, testerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var req RunPreviewRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	testCaseIDs, err := s.previewTestCaseIDs(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(testCaseIDs) > s.cfg.MaxRunCases {
+		http.Error(w, fmt.Sprintf("Selection has %d test cases, which exceeds the maximum of %d per run",
+			len(testCaseIDs), s.cfg.MaxRunCases), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	opts := RunOptions{Environment: r.URL.Query().Get("environment")}
+	if v := r.URL.Query().Get("skip_passed"); v != "" {
+		if opts.SkipPassed, err = strconv.ParseBool(v); err != nil {
+			writeError(w, validationError("skip_passed must be true or false"))
+			return
+		}
+	}
+
+	tx, err := begin(r.Context(), s.db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cases, err := s.loadRunnableCases(tx, testCaseIDs, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	preview := RunPreview{Cases: []RunPreviewCase{}, NotFound: []uuid.UUID{}}
+	action := make(map[uuid.UUID]string, len(cases))
+	for _, c := range cases {
+		pc := RunPreviewCase{TestCaseID: c.id, TestCaseName: c.name, Action: previewRun, SkippedIfFailed: []uuid.UUID{}}
+		if c.alreadyPassed {
+			pc.Action = previewSkip
+			pc.Reason = "latest result passed"
+			action[c.id] = previewRun
+			preview.Cases = append(preview.Cases, pc)
+			continue
+		}
+		for _, dep := range c.dependsOn {
+			a, ok := action[dep]
+			if !ok {
+				continue
+			}
+			pc.SkippedIfFailed = append(pc.SkippedIfFailed, dep)
+			if a != previewRun && pc.Action == previewRun {
+				pc.Action = previewSkip
+				pc.Reason = fmt.Sprintf("dependency %s will not pass", dep)
+			}
+		}
+		if c.setupErr != nil && pc.Action == previewRun {
+			pc.Action = previewError
+			pc.Reason = c.setupErr.Error()
+		}
+		action[c.id] = pc.Action
+		preview.Cases = append(preview.Cases, pc)
+	}
+	for _, id := range testCaseIDs {
+		if _, ok := action[id]; !ok {
+			preview.NotFound = append(preview.NotFound, id)
+			action[id] = ""
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
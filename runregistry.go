@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	runRunning   = "running"
+	runCompleted = "completed"
+	runCancelled = "cancelled"
+	runFailed    = "failed"
+)
+
+// ActiveRun describes a run executing in this process.
+type ActiveRun struct {
+	RunID      uuid.UUID `json:"run_id"`
+	Label      string    `json:"label,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Total      int       `json:"total"`
+	Completed  int       `json:"completed"`
+	Cancelling bool      `json:"cancelling"`
+}
+
+type registeredRun struct {
+	ActiveRun
+	cancel context.CancelFunc
+}
+
+// runRegistry tracks the runs executing in this process so they can be
+// listed and cancelled while in flight. Every start must be paired with a
+// finish, which releases the run's context; finishRun defers it.
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[uuid.UUID]*registeredRun
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: make(map[uuid.UUID]*registeredRun)}
+}
+
+func (r *runRegistry) start(id uuid.UUID, label string, total int) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[id] = &registeredRun{
+		ActiveRun: ActiveRun{RunID: id, Label: label, StartedAt: time.Now().UTC(), Total: total},
+		cancel:    cancel,
+	}
+	return ctx
+}
+
+// cancel reports whether id was running here.
+func (r *runRegistry) cancel(id uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if ok {
+		run.Cancelling = true
+		run.cancel()
+	}
+	return ok
+}
+
+func (r *runRegistry) progress(id uuid.UUID, completed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if run, ok := r.runs[id]; ok {
+		run.Completed = completed
+	}
+}
+
+func (r *runRegistry) finish(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if run, ok := r.runs[id]; ok {
+		run.cancel()
+		delete(r.runs, id)
+	}
+}
+
+// list returns a snapshot of the running runs, oldest first.
+func (r *runRegistry) list() []ActiveRun {
+	r.mu.Lock()
+	runs := make([]ActiveRun, 0, len(r.runs))
+	for _, run := range r.runs {
+		runs = append(runs, run.ActiveRun)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	return runs
+}
@@ -0,0 +1,1257 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// caseOutcome is what executing a single test case produced. Details holds
+// whatever the executor captured about a failure (request, response, diff)
+// and is stored verbatim on the run result.
+type caseOutcome struct {
+	Status  RunStatus
+	Details json.RawMessage
+}
+
+// statusFunc executes a single test case with its json_data, placeholders
+// already substituted. It should stop and return once ctx is done; runCase
+// stops waiting for it either way.
+type statusFunc func(ctx context.Context, testCaseID uuid.UUID, jsonData json.RawMessage) caseOutcome
+
+func coinFlipStatus(context.Context, uuid.UUID, json.RawMessage) caseOutcome {
+	if time.Now().Unix()%2 == 0 {
+		return caseOutcome{Status: statusFailed, Details: json.RawMessage(`{"error":"simulated failure"}`)}
+	}
+	return caseOutcome{Status: statusPassed}
+}
+
+type runnableCase struct {
+	id            uuid.UUID
+	name          string
+	projectID     uuid.UUID
+	requirementID string
+	dependsOn     []uuid.UUID
+	timeout       time.Duration
+	jsonData      json.RawMessage
+	// setupErr is set when json_data could not be prepared; the case is
+	// then recorded as an error without executing.
+	setupErr error
+	// alreadyPassed marks a case skipped by skip_passed. It counts as
+	// passed for its dependents.
+	alreadyPassed bool
+}
+
+func (s *Server) queryIDs(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// idSet runs a query that matches ids with "= ANY($1)" and returns the IDs
+// it selects as a set. An empty ids returns an empty set without querying.
+func (s *Server) idSet(ctx context.Context, query string, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	set := make(map[uuid.UUID]bool, len(ids))
+	if len(ids) == 0 {
+		return set, nil
+	}
+
+	found, err := s.queryIDs(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// requireVisibleCases writes a 404 and returns false unless every one of
+// testCaseIDs exists in a project the caller can access.
+func (s *Server) requireVisibleCases(ctx context.Context, w http.ResponseWriter, userID uuid.UUID, role string, testCaseIDs []uuid.UUID) bool {
+	if seesAllProjects(userID, role) {
+		return true
+	}
+
+	visible, err := s.queryIDs(ctx, `
+		SELECT id FROM test_cases
+		WHERE id = ANY($1) AND project_id IN (SELECT project_id FROM project_members WHERE user_id = $2)`,
+		pq.Array(testCaseIDs), userID)
+	if err != nil {
+		writeError(w, err)
+		return false
+	}
+	for _, id := range testCaseIDs {
+		if !slices.Contains(visible, id) {
+			writeError(w, notFoundError(fmt.Sprintf("Test case %s not found", id)))
+			return false
+		}
+	}
+	return true
+}
+
+// canAccessRun reports whether run runID exists and none of the cases it
+// selected, or recorded results for, is in a project the caller cannot
+// access.
+func (s *Server) canAccessRun(ctx context.Context, userID uuid.UUID, role string, runID uuid.UUID) (bool, error) {
+	if seesAllProjects(userID, role) {
+		return true, nil
+	}
+
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM test_runs WHERE id = $1) AND NOT EXISTS(
+			SELECT 1 FROM test_cases
+			WHERE (id IN (SELECT unnest(test_case_ids) FROM test_runs WHERE id = $1)
+				OR id IN (SELECT test_case_id FROM test_run_results WHERE run_id = $1))
+			AND project_id NOT IN (SELECT project_id FROM project_members WHERE user_id = $2))`,
+		runID, userID).Scan(&allowed)
+	return allowed, err
+}
+
+const maxRunLabelLength = 100
+
+func (o RunOptions) validate() error {
+	if len(o.Label) > maxRunLabelLength {
+		return fmt.Errorf("label must be at most %d characters", maxRunLabelLength)
+	}
+	return nil
+}
+
+// decodeRunOptions reads the optional body of the entity and project run
+// endpoints; an empty body means no options.
+func decodeRunOptions(r *http.Request) (RunOptions, error) {
+	var opts RunOptions
+	if err := decodeOptionalBody(r, &opts); err != nil {
+		return opts, err
+	}
+	return opts, opts.validate()
+}
+
+func (o RunOptions) rerunOf() *uuid.UUID {
+	if o.RerunOf == uuid.Nil {
+		return nil
+	}
+	return &o.RerunOf
+}
+
+// startRun records a new run as running and registers it for cancellation.
+// The row is committed right away so the run can be looked up and
+// cancelled while its results are still being written. The selection is
+// stored with it so the run can be repeated later.
+func (s *Server) startRun(ctx context.Context, testCaseIDs []uuid.UUID, opts RunOptions) (uuid.UUID, context.Context, error) {
+	runID := uuid.New()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO test_runs (id, label, status, test_case_ids, environment, rerun_of)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6)`,
+		runID, opts.Label, runRunning, pq.Array(testCaseIDs), opts.Environment,
+		uuid.NullUUID{UUID: opts.RerunOf, Valid: opts.RerunOf != uuid.Nil})
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	return runID, s.runs.start(runID, opts.Label, len(testCaseIDs)), nil
+}
+
+// finishRun executes the cases of a started run and stores the results.
+// Cases execute outside any transaction; the results and the final run
+// status are then written in one short transaction, which can be retried
+// without executing anything again. Once ctx is cancelled the remaining
+// cases are recorded as cancelled and the run ends cancelled; the database
+// work is not cancelled with it, so the outcome is still stored.
+func (s *Server) finishRun(ctx context.Context, runID uuid.UUID, testCaseIDs []uuid.UUID, opts RunOptions) (TestCaseRunResponse, error) {
+	defer s.runs.finish(runID)
+	dbCtx := context.WithoutCancel(ctx)
+
+	response := TestCaseRunResponse{
+		RunID:   runID,
+		Label:   opts.Label,
+		RerunOf: opts.rerunOf(),
+		Results: []TestCaseRunResult{},
+		Skipped: []uuid.UUID{},
+	}
+
+	notified, err := s.executeRun(ctx, &response, testCaseIDs, opts)
+	if err == nil {
+		err = s.saveRunResults(dbCtx, response)
+	}
+	if err != nil {
+		if _, markErr := s.db.ExecContext(dbCtx, `UPDATE test_runs SET status = $1, finished_at = NOW() WHERE id = $2`, runFailed, runID); markErr != nil {
+			s.logger.Error("mark run failed", "run", runID, "err", markErr)
+		}
+		return response, err
+	}
+
+	s.notifyRun(response.RunID, notified, response.Results)
+
+	return response, nil
+}
+
+// executeRun runs the cases of testCaseIDs in dependency order and fills in
+// the results, skipped cases and final status of response. It returns the
+// cases that have a result. Each case is guarded by a session advisory lock
+// on a dedicated connection, so two runs never execute the same case at
+// once; a case that is locked is skipped. The locks are held until the
+// run is done.
+func (s *Server) executeRun(ctx context.Context, response *TestCaseRunResponse, testCaseIDs []uuid.UUID, opts RunOptions) ([]runnableCase, error) {
+	dbCtx := context.WithoutCancel(ctx)
+
+	var cases []runnableCase
+	err := withTxOptions(dbCtx, s.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *Tx) error {
+		var err error
+		cases, err = s.loadRunnableCases(tx, testCaseIDs, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.db.Conn(dbCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseAdvisoryLocks(conn)
+
+	var notified []runnableCase
+	outcome := make(map[uuid.UUID]RunStatus, len(cases))
+	for _, c := range cases {
+		var result TestCaseRunResult
+		if ctx.Err() != nil {
+			result = TestCaseRunResult{TestCaseID: c.id, Status: statusCancelled, RunTime: time.Now()}
+		} else {
+			var locked bool
+			err := conn.QueryRowContext(dbCtx,
+				`SELECT pg_try_advisory_lock(hashtextextended($1::text, 0))`, c.id).Scan(&locked)
+			if err != nil {
+				return nil, err
+			}
+			if !locked {
+				response.Skipped = append(response.Skipped, c.id)
+				outcome[c.id] = "locked"
+				continue
+			}
+			result = s.runCase(ctx, c, outcome)
+		}
+
+		result.TestCaseName = c.name
+		outcome[c.id] = result.Status
+		if c.alreadyPassed && result.Status == statusSkipped {
+			outcome[c.id] = statusPassed
+			response.SkippedPassed++
+		}
+
+		response.Results = append(response.Results, result)
+		notified = append(notified, c)
+		s.runs.progress(response.RunID, len(response.Results))
+	}
+
+	response.Status = runCompleted
+	if ctx.Err() != nil {
+		response.Status = runCancelled
+	}
+	return notified, nil
+}
+
+// releaseAdvisoryLocks drops the session advisory locks taken by
+// executeRun and returns conn to the pool. A connection whose locks could
+// not be released is discarded instead, which releases them too.
+func (s *Server) releaseAdvisoryLocks(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock_all()`); err != nil {
+		s.logger.Error("release run locks", "err", err)
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// saveRunResults stores the results of an executed run and its final
+// status in one transaction.
+func (s *Server) saveRunResults(ctx context.Context, response TestCaseRunResponse) error {
+	return withTxOptions(ctx, s.db, &sql.TxOptions{Isolation: s.runIsolation}, func(tx *Tx) error {
+		for _, result := range response.Results {
+			var duration sql.NullInt64
+			if result.Status != statusSkipped && result.Status != statusCancelled {
+				duration = sql.NullInt64{Int64: result.DurationMs, Valid: true}
+			}
+			_, err := tx.Exec(`
+				INSERT INTO test_run_results (run_id, test_case_id, status, run_time, duration_ms, details)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+				response.RunID, result.TestCaseID, result.Status, result.RunTime, duration, nullableJSON(result.details))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`UPDATE test_runs SET status = $1, finished_at = NOW() WHERE id = $2`, response.Status, response.RunID)
+		return err
+	})
+}
+
+// loadRunnableCases loads the cases of testCaseIDs that exist, prepared for
+// execution and in the order they would run.
+func (s *Server) loadRunnableCases(tx *Tx, testCaseIDs []uuid.UUID, opts RunOptions) ([]runnableCase, error) {
+	query := `SELECT id, name, project_id, requirement_id, depends_on, timeout_ms, json_data FROM test_cases WHERE id = ANY($1)`
+	rows, err := tx.Query(query, pq.Array(testCaseIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []runnableCase
+	for rows.Next() {
+		var c runnableCase
+		var requirementID sql.NullString
+		var timeoutMs sql.NullInt64
+		var jsonData []byte
+		if err := rows.Scan(&c.id, &c.name, &c.projectID, &requirementID, pq.Array(&c.dependsOn), &timeoutMs, &jsonData); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.requirementID = requirementID.String
+		c.jsonData, c.setupErr = s.openJSONData(jsonData)
+		c.timeout = s.cfg.CaseTimeout
+		if timeoutMs.Valid {
+			c.timeout = time.Duration(timeoutMs.Int64) * time.Millisecond
+		}
+		cases = append(cases, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.Environment != "" {
+		if err := s.applyEnvironment(tx, opts.Environment, cases); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SkipPassed {
+		if err := markAlreadyPassed(tx, cases); err != nil {
+			return nil, err
+		}
+	}
+
+	return orderByDependencies(cases), nil
+}
+
+// markAlreadyPassed flags the cases whose most recent stored result passed.
+func markAlreadyPassed(tx *Tx, cases []runnableCase) error {
+	ids := make([]uuid.UUID, 0, len(cases))
+	for _, c := range cases {
+		ids = append(ids, c.id)
+	}
+	rows, err := tx.Query(`
+		SELECT test_case_id FROM (
+			SELECT DISTINCT ON (test_case_id) test_case_id, status
+			FROM test_run_results WHERE test_case_id = ANY($1)
+			ORDER BY test_case_id, run_time DESC
+		) latest WHERE status = $2`, pq.Array(ids), statusPassed)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	passed := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		passed[id] = true
+	}
+	for i := range cases {
+		cases[i].alreadyPassed = passed[cases[i].id]
+	}
+	return rows.Err()
+}
+
+// respondRun runs testCaseIDs for a run endpoint. With ?async=true it
+// answers 202 as soon as the run is recorded and executes it in the
+// background; the results appear under /runs/:runId/results.
+func (s *Server) respondRun(w http.ResponseWriter, r *http.Request, testCaseIDs []uuid.UUID, opts RunOptions) {
+	order := r.URL.Query().Get("order")
+	if order == "" {
+		order = resultOrderInput
+	}
+	if !slices.Contains(resultOrders, order) {
+		writeError(w, validationError("order must be one of "+strings.Join(resultOrders, ", ")))
+		return
+	}
+
+	if env := r.URL.Query().Get("environment"); env != "" {
+		opts.Environment = env
+	}
+	if v := r.URL.Query().Get("skip_passed"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, validationError("skip_passed must be true or false"))
+			return
+		}
+		opts.SkipPassed = skip
+	}
+	if opts.Environment != "" {
+		var missing int
+		err := s.db.QueryRowContext(r.Context(), `
+			SELECT COUNT(DISTINCT tc.project_id) FROM test_cases tc
+			WHERE tc.id = ANY($1) AND NOT EXISTS (
+				SELECT 1 FROM project_environments pe WHERE pe.project_id = tc.project_id AND pe.name = $2
+			)`, pq.Array(testCaseIDs), opts.Environment).Scan(&missing)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if missing > 0 {
+			writeError(w, validationError(fmt.Sprintf("Environment %q is not defined for every project in the run", opts.Environment)))
+			return
+		}
+	}
+
+	runID, ctx, err := s.startRun(r.Context(), testCaseIDs, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("async") == "true" {
+		go func() {
+			if _, err := s.finishRun(ctx, runID, testCaseIDs, opts); err != nil {
+				s.logger.Error("async run failed", "run", runID, "err", err)
+			}
+		}()
+
+		w.Header().Set("Location", s.path("/runs/"+runID.String()+"/results"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TestCaseRunResponse{
+			RunID:   runID,
+			Label:   opts.Label,
+			Status:  runRunning,
+			RerunOf: opts.rerunOf(),
+			Results: []TestCaseRunResult{},
+			Skipped: []uuid.UUID{},
+		})
+		return
+	}
+
+	// A synchronous run is cancelled like POST /runs/:runId/cancel when the
+	// request is abandoned or times out, so it stops executing cases nobody
+	// will see the results of.
+	stop := context.AfterFunc(r.Context(), func() { s.runs.cancel(runID) })
+	defer stop()
+
+	response, err := s.finishRun(ctx, runID, testCaseIDs, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sortRunResults(response.Results, order, testCaseIDs)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+const (
+	resultOrderInput     = "input"
+	resultOrderName      = "name"
+	resultOrderExecution = "execution"
+)
+
+var resultOrders = []string{resultOrderInput, resultOrderName, resultOrderExecution}
+
+// sortRunResults puts results in the requested order. Results come back in
+// execution order, which follows dependencies rather than the request.
+func sortRunResults(results []TestCaseRunResult, order string, testCaseIDs []uuid.UUID) {
+	switch order {
+	case resultOrderInput:
+		position := make(map[uuid.UUID]int, len(testCaseIDs))
+		for i, id := range testCaseIDs {
+			if _, ok := position[id]; !ok {
+				position[id] = i
+			}
+		}
+		sort.SliceStable(results, func(a, b int) bool {
+			return position[results[a].TestCaseID] < position[results[b].TestCaseID]
+		})
+	case resultOrderName:
+		sort.SliceStable(results, func(a, b int) bool {
+			return results[a].TestCaseName < results[b].TestCaseName
+		})
+	}
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	runID, err := uuid.Parse(ps.ByName("runId"))
+	if err != nil {
+		writeError(w, validationError("Invalid run ID"))
+		return
+	}
+
+	allowed, err := s.canAccessRun(r.Context(), userID, role, runID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Run not found"))
+		return
+	}
+
+	if s.runs.cancel(runID) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"run_id": runID, "status": runCancelled})
+		return
+	}
+
+	var status string
+	err = s.db.QueryRowContext(r.Context(), `SELECT status FROM test_runs WHERE id = $1`, runID).Scan(&status)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Run not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == runRunning {
+		writeError(w, conflictError("Run is not executing on this server"))
+		return
+	}
+	writeError(w, conflictError(fmt.Sprintf("Run already %s", status)))
+}
+
+// activeRuns lists the runs executing in this server process, including
+// async runs, with how many of their cases have finished.
+func (s *Server) activeRuns(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	runs := []ActiveRun{}
+	for _, run := range s.runs.list() {
+		allowed, err := s.canAccessRun(r.Context(), userID, role, run.RunID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if allowed {
+			runs = append(runs, run)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// rerun starts a new run over the selection of an earlier one, keeping its
+// label and environment unless the request overrides them. Runs recorded
+// before selections were stored fall back to the cases that have results.
+func (s *Server) rerun(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	runID, err := uuid.Parse(ps.ByName("runId"))
+	if err != nil {
+		writeError(w, validationError("Invalid run ID"))
+		return
+	}
+
+	allowed, err := s.canAccessRun(r.Context(), userID, testerRole, runID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Run not found"))
+		return
+	}
+
+	opts, err := decodeRunOptions(r)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	var label, environment string
+	var testCaseIDs []uuid.UUID
+	err = s.db.QueryRowContext(r.Context(), `SELECT COALESCE(label, ''), COALESCE(environment, ''), test_case_ids FROM test_runs WHERE id = $1`, runID).
+		Scan(&label, &environment, pq.Array(&testCaseIDs))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Run not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if testCaseIDs == nil {
+		testCaseIDs, err = s.queryIDs(r.Context(), `
+			SELECT test_case_id FROM test_run_results WHERE run_id = $1
+			GROUP BY test_case_id ORDER BY MIN(run_time)`, runID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if len(testCaseIDs) == 0 {
+		writeError(w, validationError("Run has no test cases to rerun"))
+		return
+	}
+
+	if opts.Label == "" {
+		opts.Label = label
+	}
+	opts.Environment = environment
+	opts.RerunOf = runID
+
+	s.respondRun(w, r, testCaseIDs, opts)
+}
+
+// runCase executes c, or skips it when one of its dependencies did not pass
+// earlier in the run. A case that outlives its timeout is recorded as an
+// error so it cannot hold up the rest of the run.
+func (s *Server) runCase(ctx context.Context, c runnableCase, outcome map[uuid.UUID]RunStatus) TestCaseRunResult {
+	result := TestCaseRunResult{
+		TestCaseID: c.id,
+		RunTime:    time.Now(),
+	}
+	if c.alreadyPassed {
+		result.Status = statusSkipped
+		result.Reason = "latest result passed"
+		result.details, _ = json.Marshal(map[string]string{"reason": result.Reason})
+		return result
+	}
+	for _, dep := range c.dependsOn {
+		if status, ok := outcome[dep]; ok && status != statusPassed {
+			result.Status = statusSkipped
+			result.Reason = fmt.Sprintf("dependency %s %s", dep, status)
+			result.details, _ = json.Marshal(map[string]string{"reason": result.Reason})
+			return result
+		}
+	}
+
+	if c.setupErr != nil {
+		result.Status = statusError
+		result.Reason = c.setupErr.Error()
+		result.details, _ = json.Marshal(map[string]string{"error": result.Reason})
+		return result
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan caseOutcome, 1)
+	go func() { done <- s.status(ctx, c.id, c.jsonData) }()
+
+	var executed caseOutcome
+	select {
+	case executed = <-done:
+	case <-ctx.Done():
+		result.DurationMs = time.Since(start).Milliseconds()
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Status = statusError
+			result.Reason = fmt.Sprintf("timed out after %s", c.timeout)
+		} else {
+			result.Status = statusCancelled
+			result.Reason = "run cancelled"
+		}
+		result.details, _ = json.Marshal(map[string]string{"reason": result.Reason})
+		return result
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Status = executed.Status
+	result.details = executed.Details
+	if !result.Status.valid() {
+		result.Status = statusError
+		result.details, _ = json.Marshal(map[string]string{
+			"error": fmt.Sprintf("executor reported unknown status %q", executed.Status),
+		})
+	}
+	return result
+}
+
+type RunResultRecord struct {
+	RunID        uuid.UUID `json:"run_id"`
+	Label        string    `json:"label,omitempty"`
+	TestCaseID   uuid.UUID `json:"test_case_id"`
+	TestCaseName string    `json:"test_case_name"`
+	Status       RunStatus `json:"status"`
+	RunTime      time.Time `json:"run_time"`
+	DurationMs   *int64    `json:"duration_ms,omitempty"`
+}
+
+const runResultRecordColumns = `rr.run_id, COALESCE(tr.label, ''), rr.test_case_id, tc.name, rr.status, rr.run_time, rr.duration_ms`
+
+func scanRunResultRecords(rows *sql.Rows) ([]RunResultRecord, error) {
+	records := []RunResultRecord{}
+	for rows.Next() {
+		var rec RunResultRecord
+		var duration sql.NullInt64
+		if err := rows.Scan(&rec.RunID, &rec.Label, &rec.TestCaseID, &rec.TestCaseName, &rec.Status, &rec.RunTime, &duration); err != nil {
+			return nil, err
+		}
+		if duration.Valid {
+			rec.DurationMs = &duration.Int64
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+type RunSummary struct {
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	PassRate float64 `json:"pass_rate"`
+}
+
+type ProjectRunsResponse struct {
+	Summary RunSummary `json:"summary"`
+	Page[RunResultRecord]
+}
+
+func (s *Server) projectRuns(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.RunsPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	var where whereClause
+	where.add("tc.project_id = " + where.arg(projectID))
+
+	q := r.URL.Query()
+	if status := RunStatus(q.Get("status")); status != "" {
+		if !status.valid() {
+			writeError(w, validationError("status must be one of "+runStatusList()))
+			return
+		}
+		where.add("rr.status = " + where.arg(status))
+	}
+	if label := q.Get("label"); label != "" {
+		where.add("tr.label = " + where.arg(label))
+	}
+	for _, bound := range []struct{ param, op string }{{"from", ">="}, {"to", "<="}} {
+		param, op := bound.param, bound.op
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, validationError(fmt.Sprintf("Invalid %s: expected RFC 3339 timestamp", param)))
+			return
+		}
+		where.add(fmt.Sprintf("rr.run_time %s %s", op, where.arg(t)))
+	}
+
+	from := ` FROM test_run_results rr JOIN test_cases tc ON tc.id = rr.test_case_id
+		JOIN test_runs tr ON tr.id = rr.run_id` + where.String()
+
+	var resp ProjectRunsResponse
+	err = s.replica.QueryRowContext(r.Context(), `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE rr.status = 'passed'),
+			COUNT(*) FILTER (WHERE rr.status = 'failed')`+from, where.args...).
+		Scan(&resp.Summary.Total, &resp.Summary.Passed, &resp.Summary.Failed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if resp.Summary.Total > 0 {
+		resp.Summary.PassRate = float64(resp.Summary.Passed) / float64(resp.Summary.Total)
+	}
+
+	args := append(where.args, limit, offset)
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+runResultRecordColumns+from+
+		fmt.Sprintf(` ORDER BY rr.run_time DESC, tc.name LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	resp.Items, err = scanRunResultRecords(rows)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp.Total = resp.Summary.Total
+	resp.Limit = limit
+	resp.Offset = offset
+	resp.setLinks(r)
+	setTotalCount(w, resp.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type RunResultDetail struct {
+	TestCaseID   uuid.UUID       `json:"test_case_id"`
+	TestCaseName string          `json:"test_case_name"`
+	EntityName   string          `json:"entity_name"`
+	Status       RunStatus       `json:"status"`
+	RunTime      time.Time       `json:"run_time"`
+	DurationMs   *int64          `json:"duration_ms,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+}
+
+type RunDetail struct {
+	RunID      uuid.UUID         `json:"run_id"`
+	Label      string            `json:"label,omitempty"`
+	Status     string            `json:"status"`
+	RerunOf    *uuid.UUID        `json:"rerun_of,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Results    []RunResultDetail `json:"results"`
+}
+
+func nullableJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
+
+func (s *Server) runResults(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	detail, ok := s.loadRunDetail(w, r, ps)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// loadRunDetail authenticates the request and loads the run named by the
+// runId parameter, limited to results in projects the caller can access.
+// On failure it writes the error response and returns false.
+func (s *Server) loadRunDetail(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (RunDetail, bool) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return RunDetail{}, false
+	}
+
+	runID, err := uuid.Parse(ps.ByName("runId"))
+	if err != nil {
+		writeError(w, validationError("Invalid run ID"))
+		return RunDetail{}, false
+	}
+
+	detail := RunDetail{RunID: runID, Results: []RunResultDetail{}}
+	var finishedAt sql.NullTime
+	var rerunOf uuid.NullUUID
+	err = s.db.QueryRowContext(r.Context(), `SELECT COALESCE(label, ''), status, rerun_of, created_at, finished_at FROM test_runs WHERE id = $1`, runID).
+		Scan(&detail.Label, &detail.Status, &rerunOf, &detail.CreatedAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Run not found"))
+		return RunDetail{}, false
+	}
+	if err != nil {
+		writeError(w, err)
+		return RunDetail{}, false
+	}
+	if finishedAt.Valid {
+		detail.FinishedAt = &finishedAt.Time
+	}
+	if rerunOf.Valid {
+		detail.RerunOf = &rerunOf.UUID
+	}
+
+	var where whereClause
+	where.add("rr.run_id = " + where.arg(runID))
+	addProjectScope(&where, "tc.project_id", userID, role)
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT rr.test_case_id, tc.name, e.name, rr.status, rr.run_time, rr.duration_ms, rr.details
+		FROM test_run_results rr
+		JOIN test_cases tc ON tc.id = rr.test_case_id
+		JOIN entities e ON e.id = tc.entity_id`+where.String()+`
+		ORDER BY rr.run_time, tc.name`, where.args...)
+	if err != nil {
+		writeError(w, err)
+		return RunDetail{}, false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res RunResultDetail
+		var details []byte
+		var duration sql.NullInt64
+		if err := rows.Scan(&res.TestCaseID, &res.TestCaseName, &res.EntityName, &res.Status, &res.RunTime, &duration, &details); err != nil {
+			writeError(w, err)
+			return RunDetail{}, false
+		}
+		if duration.Valid {
+			res.DurationMs = &duration.Int64
+		}
+		res.Details = details
+		detail.Results = append(detail.Results, res)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return RunDetail{}, false
+	}
+
+	return detail, true
+}
+
+type DurationStats struct {
+	Runs  int      `json:"runs"`
+	AvgMs *float64 `json:"avg_ms"`
+	P50Ms *float64 `json:"p50_ms"`
+	P95Ms *float64 `json:"p95_ms"`
+	MaxMs *int64   `json:"max_ms"`
+}
+
+type TestCaseRunHistory struct {
+	Durations DurationStats `json:"durations"`
+	Page[RunResultRecord]
+}
+
+func (s *Server) testCaseRuns(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	testCaseID, err := uuid.Parse(ps.ByName("testCaseId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test case ID"))
+		return
+	}
+
+	var projectID uuid.UUID
+	err = s.replica.QueryRowContext(r.Context(), `SELECT project_id FROM test_cases WHERE id = $1`, testCaseID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Test case not found"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.RunsPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	var resp TestCaseRunHistory
+	var avg, p50, p95 sql.NullFloat64
+	var maxMs sql.NullInt64
+	err = s.replica.QueryRowContext(r.Context(), `
+		SELECT COUNT(*),
+			AVG(duration_ms),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms),
+			MAX(duration_ms)
+		FROM test_run_results WHERE test_case_id = $1`, testCaseID).
+		Scan(&resp.Total, &avg, &p50, &p95, &maxMs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp.Durations.Runs = resp.Total
+	if avg.Valid {
+		resp.Durations.AvgMs = &avg.Float64
+		resp.Durations.P50Ms = &p50.Float64
+		resp.Durations.P95Ms = &p95.Float64
+		resp.Durations.MaxMs = &maxMs.Int64
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+runResultRecordColumns+`
+		FROM test_run_results rr JOIN test_cases tc ON tc.id = rr.test_case_id
+		JOIN test_runs tr ON tr.id = rr.run_id
+		WHERE rr.test_case_id = $1
+		ORDER BY rr.run_time DESC LIMIT $2 OFFSET $3`, testCaseID, limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	resp.Items, err = scanRunResultRecords(rows)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp.Limit = limit
+	resp.Offset = offset
+	resp.setLinks(r)
+	setTotalCount(w, resp.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type TestCaseStatus struct {
+	TestCaseID uuid.UUID  `json:"test_case_id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	RunID      *uuid.UUID `json:"run_id,omitempty"`
+	RunTime    *time.Time `json:"run_time,omitempty"`
+}
+
+// entityStatus lists the test cases of an entity with the status of their
+// latest run; cases that never ran report "not_run".
+func (s *Server) entityStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	entityID, err := uuid.Parse(ps.ByName("entityId"))
+	if err != nil {
+		writeError(w, validationError("Invalid entity ID"))
+		return
+	}
+
+	var projectID uuid.UUID
+	err = s.replica.QueryRowContext(r.Context(), `SELECT project_id FROM entities WHERE id = $1`, entityID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Entity not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Entity not found"))
+		return
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.TestCasesPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	page := Page[TestCaseStatus]{Items: []TestCaseStatus{}, Limit: limit, Offset: offset}
+	if err := s.replica.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM test_cases WHERE entity_id = $1`, entityID).Scan(&page.Total); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT tc.id, tc.name, latest.status, latest.run_id, latest.run_time
+		FROM test_cases tc
+		LEFT JOIN (
+			SELECT DISTINCT ON (rr.test_case_id) rr.test_case_id, rr.status, rr.run_id, rr.run_time
+			FROM test_run_results rr
+			JOIN test_cases t ON t.id = rr.test_case_id
+			WHERE t.entity_id = $1
+			ORDER BY rr.test_case_id, rr.run_time DESC
+		) latest ON latest.test_case_id = tc.id
+		WHERE tc.entity_id = $1
+		ORDER BY tc.name, tc.id
+		LIMIT $2 OFFSET $3`, entityID, limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tc TestCaseStatus
+		var status sql.NullString
+		var runID uuid.NullUUID
+		var runTime sql.NullTime
+		if err := rows.Scan(&tc.TestCaseID, &tc.Name, &status, &runID, &runTime); err != nil {
+			writeError(w, err)
+			return
+		}
+		tc.Status = "not_run"
+		if status.Valid {
+			tc.Status = status.String
+		}
+		if runID.Valid {
+			tc.RunID = &runID.UUID
+		}
+		if runTime.Valid {
+			tc.RunTime = &runTime.Time
+		}
+		page.Items = append(page.Items, tc)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	page.setLinks(r)
+	setTotalCount(w, page.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+type AssignedTestCase struct {
+	TestCaseStatus
+	ProjectID uuid.UUID `json:"project_id"`
+	EntityID  uuid.UUID `json:"entity_id"`
+	Priority  string    `json:"priority"`
+	Severity  string    `json:"severity"`
+}
+
+// myTestCases is the caller's work queue: the test cases assigned to them
+// in any project, most urgent first, with their latest status. ?status
+// filters on that status, where "not_run" matches cases that never ran.
+func (s *Server) myTestCases(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, _, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.TestCasesPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	var where whereClause
+	where.add("tc.assigned_to = " + where.arg(userID))
+	if status := RunStatus(r.URL.Query().Get("status")); status != "" {
+		if status != "not_run" && !status.valid() {
+			writeError(w, validationError("status must be one of not_run, "+runStatusList()))
+			return
+		}
+		where.add("COALESCE(latest.status, 'not_run') = " + where.arg(status))
+	}
+
+	from := `
+		FROM test_cases tc
+		LEFT JOIN LATERAL (
+			SELECT rr.status, rr.run_id, rr.run_time FROM test_run_results rr
+			WHERE rr.test_case_id = tc.id
+			ORDER BY rr.run_time DESC LIMIT 1
+		) latest ON true` + where.String()
+
+	page := Page[AssignedTestCase]{Items: []AssignedTestCase{}, Limit: limit, Offset: offset}
+	if err := s.replica.QueryRowContext(r.Context(), `SELECT COUNT(*)`+from, where.args...).Scan(&page.Total); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	args := append(where.args, limit, offset)
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT tc.id, tc.name, tc.project_id, tc.entity_id, tc.priority, tc.severity, latest.status, latest.run_id, latest.run_time`+from+
+		fmt.Sprintf(` ORDER BY array_position(ARRAY['low', 'medium', 'high', 'critical']::varchar[], tc.priority) DESC, tc.name, tc.id
+			LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tc AssignedTestCase
+		var status sql.NullString
+		var runID uuid.NullUUID
+		var runTime sql.NullTime
+		err := rows.Scan(&tc.TestCaseID, &tc.Name, &tc.ProjectID, &tc.EntityID, &tc.Priority, &tc.Severity, &status, &runID, &runTime)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		tc.Status = "not_run"
+		if status.Valid {
+			tc.Status = status.String
+		}
+		if runID.Valid {
+			tc.RunID = &runID.UUID
+		}
+		if runTime.Valid {
+			tc.RunTime = &runTime.Time
+		}
+		page.Items = append(page.Items, tc)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	page.setLinks(r)
+	setTotalCount(w, page.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
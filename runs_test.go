@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestRunResultOrder(t *testing.T) {
+	// first depends on third, so the run executes third, first, second.
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	projectID := uuid.New()
+	caseRow := func(id uuid.UUID, name string, dependsOn ...uuid.UUID) []driver.Value {
+		deps := make([]string, len(dependsOn))
+		for i, dep := range dependsOn {
+			deps[i] = dep.String()
+		}
+		return []driver.Value{id.String(), name, projectID.String(), nil, "{" + strings.Join(deps, ",") + "}", nil, []byte(`{}`)}
+	}
+	body := `{"test_case_ids": ["` + first.String() + `", "` + second.String() + `", "` + third.String() + `"]}`
+
+	for _, tt := range []struct {
+		order string
+		want  []uuid.UUID
+	}{
+		{"input", []uuid.UUID{first, second, third}},
+		{"execution", []uuid.UUID{third, first, second}},
+	} {
+		t.Run(tt.order, func(t *testing.T) {
+			s, f := newTestServer(t)
+			s.status = func(context.Context, uuid.UUID, json.RawMessage) caseOutcome {
+				return caseOutcome{Status: statusPassed}
+			}
+			token := tokenFor(t, s, f, testerRole)
+			f.on("FROM project_members WHERE user_id", []string{"id"},
+				[]driver.Value{first.String()}, []driver.Value{second.String()}, []driver.Value{third.String()})
+			f.on("SELECT id, name, project_id, requirement_id", []string{"id", "name", "project_id", "requirement_id", "depends_on", "timeout_ms", "json_data"},
+				caseRow(first, "a", third), caseRow(second, "b"), caseRow(third, "c"))
+			f.on("pg_try_advisory_lock", []string{"locked"}, []driver.Value{true})
+			f.on("pg_advisory_unlock_all", nil)
+			f.on("INSERT INTO test_run", nil)
+			f.on("UPDATE test_runs", nil)
+			f.on("SELECT id, batch_notifications, notification_routes FROM projects", []string{"id", "batch_notifications", "notification_routes"})
+
+			rec := serve(s, http.MethodPost, "/v1/testcases/run?order="+tt.order, token, body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			var resp TestCaseRunResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var got []uuid.UUID
+			for _, result := range resp.Results {
+				got = append(got, result.TestCaseID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("result %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunHidesCasesOutsideCallerProjects(t *testing.T) {
+	visible, hidden := uuid.New(), uuid.New()
+	s, f := newTestServer(t)
+	token := tokenFor(t, s, f, testerRole)
+	f.on("FROM project_members WHERE user_id", []string{"id"}, []driver.Value{visible.String()})
+
+	rec := serve(s, http.MethodPost, "/v1/testcases/run", token,
+		`{"test_case_ids": ["`+visible.String()+`", "`+hidden.String()+`"]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if n := len(f.executed("INSERT INTO test_runs")); n != 0 {
+		t.Errorf("%d runs started, want 0", n)
+	}
+}
+
+func TestRunRetryDoesNotReexecuteCases(t *testing.T) {
+	caseID, projectID := uuid.New(), uuid.New()
+	s, f := newTestServer(t)
+	var executions atomic.Int32
+	s.status = func(context.Context, uuid.UUID, json.RawMessage) caseOutcome {
+		executions.Add(1)
+		return caseOutcome{Status: statusPassed}
+	}
+	token := tokenFor(t, s, f, testerRole)
+	f.on("FROM project_members WHERE user_id", []string{"id"}, []driver.Value{caseID.String()})
+	f.on("SELECT id, name, project_id, requirement_id", []string{"id", "name", "project_id", "requirement_id", "depends_on", "timeout_ms", "json_data"},
+		[]driver.Value{caseID.String(), "a", projectID.String(), nil, "{}", nil, []byte(`{}`)})
+	f.on("pg_try_advisory_lock", []string{"locked"}, []driver.Value{true})
+	f.on("pg_advisory_unlock_all", nil)
+	f.fail("INSERT INTO test_run_results", &pq.Error{Code: "40001"})
+	f.on("INSERT INTO test_runs", nil)
+	f.on("UPDATE test_runs", nil)
+
+	rec := serve(s, http.MethodPost, "/v1/testcases/run", token, `{"test_case_ids": ["`+caseID.String()+`"]}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if n := len(f.executed("INSERT INTO test_run_results")); n != maxTxAttempts {
+		t.Errorf("results written %d times, want %d", n, maxTxAttempts)
+	}
+	if n := executions.Load(); n != 1 {
+		t.Errorf("case executed %d times, want 1", n)
+	}
+}
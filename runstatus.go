@@ -0,0 +1,33 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// RunStatus is the outcome of one test case in a run. The set is closed and
+// mirrors the test_run_results_status_check constraint.
+type RunStatus string
+
+const (
+	statusPassed    RunStatus = "passed"
+	statusFailed    RunStatus = "failed"
+	statusSkipped   RunStatus = "skipped"
+	statusBlocked   RunStatus = "blocked"
+	statusError     RunStatus = "error"
+	statusCancelled RunStatus = "cancelled"
+)
+
+var runStatuses = []RunStatus{statusPassed, statusFailed, statusSkipped, statusBlocked, statusError, statusCancelled}
+
+func (s RunStatus) valid() bool {
+	return slices.Contains(runStatuses, s)
+}
+
+func runStatusList() string {
+	names := make([]string, len(runStatuses))
+	for i, s := range runStatuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
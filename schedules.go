@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/robfig/cron/v3"
+)
+
+type Schedule struct {
+	ID        uuid.UUID  `json:"id"`
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+	EntityID  *uuid.UUID `json:"entity_id,omitempty"`
+	CronExpr  string     `json:"cron"`
+	Label     string     `json:"label,omitempty"`
+	Enabled   bool       `json:"enabled"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+const scheduleColumns = `id, project_id, entity_id, cron_expr, COALESCE(label, ''), enabled, last_run_at, next_run_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (Schedule, error) {
+	var sc Schedule
+	var projectID, entityID uuid.NullUUID
+	var lastRun, nextRun sql.NullTime
+	err := row.Scan(&sc.ID, &projectID, &entityID, &sc.CronExpr, &sc.Label, &sc.Enabled, &lastRun, &nextRun)
+	if err != nil {
+		return sc, err
+	}
+	if projectID.Valid {
+		sc.ProjectID = &projectID.UUID
+	}
+	if entityID.Valid {
+		sc.EntityID = &entityID.UUID
+	}
+	if lastRun.Valid {
+		sc.LastRunAt = &lastRun.Time
+	}
+	if nextRun.Valid {
+		sc.NextRunAt = &nextRun.Time
+	}
+	return sc, nil
+}
+
+func (sc *Schedule) validate() error {
+	if (sc.ProjectID == nil) == (sc.EntityID == nil) {
+		return fmt.Errorf("exactly one of project_id and entity_id is required")
+	}
+	if _, err := cron.ParseStandard(sc.CronExpr); err != nil {
+		return fmt.Errorf("invalid cron expression: %v", err)
+	}
+	if len(sc.Label) > maxRunLabelLength {
+		return fmt.Errorf("label must be at most %d characters", maxRunLabelLength)
+	}
+	return nil
+}
+
+// scheduler keeps one cron entry per enabled schedule. It is started from
+// main so that servers built without a database never touch it.
+type scheduler struct {
+	mu      sync.Mutex
+	cron    *cron.Cron
+	entries map[uuid.UUID]cron.EntryID
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		cron:    cron.New(),
+		entries: make(map[uuid.UUID]cron.EntryID),
+	}
+}
+
+func (s *Server) startScheduler(ctx context.Context) error {
+	s.scheduler = newScheduler()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE enabled`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			return err
+		}
+		if err := s.scheduleRun(ctx, sc); err != nil {
+			s.logger.Error("schedule could not be registered", "schedule", sc.ID, "err", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.scheduler.cron.Start()
+	return nil
+}
+
+// scheduleRun (re)registers sc with the cron runner and stores its next
+// run time. Disabled schedules are only removed.
+func (s *Server) scheduleRun(ctx context.Context, sc Schedule) error {
+	s.scheduler.mu.Lock()
+	defer s.scheduler.mu.Unlock()
+
+	if id, ok := s.scheduler.entries[sc.ID]; ok {
+		s.scheduler.cron.Remove(id)
+		delete(s.scheduler.entries, sc.ID)
+	}
+	if !sc.Enabled {
+		_, err := s.db.ExecContext(ctx, `UPDATE schedules SET next_run_at = NULL WHERE id = $1`, sc.ID)
+		return err
+	}
+
+	spec, err := cron.ParseStandard(sc.CronExpr)
+	if err != nil {
+		return err
+	}
+	s.scheduler.entries[sc.ID] = s.scheduler.cron.Schedule(spec, cron.FuncJob(func() { s.runSchedule(sc.ID) }))
+
+	_, err = s.db.ExecContext(ctx, `UPDATE schedules SET next_run_at = $1 WHERE id = $2`, spec.Next(time.Now()), sc.ID)
+	return err
+}
+
+func (s *Server) unscheduleRun(id uuid.UUID) {
+	s.scheduler.mu.Lock()
+	defer s.scheduler.mu.Unlock()
+
+	if entry, ok := s.scheduler.entries[id]; ok {
+		s.scheduler.cron.Remove(entry)
+		delete(s.scheduler.entries, id)
+	}
+}
+
+func (s *Server) runSchedule(id uuid.UUID) {
+	ctx := context.Background()
+	sc, err := scanSchedule(s.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id))
+	if err != nil {
+		s.logger.Error("load schedule", "schedule", id, "err", err)
+		return
+	}
+
+	var testCaseIDs []uuid.UUID
+	if sc.ProjectID != nil {
+		testCaseIDs, err = s.queryIDs(ctx, `SELECT id FROM test_cases WHERE project_id = $1`, *sc.ProjectID)
+	} else {
+		testCaseIDs, err = s.queryIDs(ctx, `SELECT id FROM test_cases WHERE entity_id = $1`, *sc.EntityID)
+	}
+	if err != nil {
+		s.logger.Error("select scheduled test cases", "schedule", id, "err", err)
+		return
+	}
+
+	spec, err := cron.ParseStandard(sc.CronExpr)
+	if err != nil {
+		s.logger.Error("parse schedule", "schedule", id, "err", err)
+		return
+	}
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx, `UPDATE schedules SET last_run_at = $1, next_run_at = $2 WHERE id = $3`, now, spec.Next(now), id); err != nil {
+		s.logger.Error("update schedule timestamps", "schedule", id, "err", err)
+	}
+
+	switch {
+	case len(testCaseIDs) == 0:
+		s.logger.Info("scheduled run has no test cases", "schedule", id)
+		return
+	case len(testCaseIDs) > s.cfg.MaxRunCases:
+		s.logger.Warn("scheduled run exceeds the maximum run size", "schedule", id,
+			"cases", len(testCaseIDs), "max", s.cfg.MaxRunCases)
+		return
+	}
+
+	label := sc.Label
+	if label == "" {
+		label = "schedule:" + sc.ID.String()
+	}
+	opts := RunOptions{Label: label}
+	runID, ctx, err := s.startRun(ctx, testCaseIDs, opts)
+	if err != nil {
+		s.logger.Error("start scheduled run", "schedule", id, "err", err)
+		return
+	}
+
+	// The run executes in the background like an async run, so a long run
+	// does not hold up the scheduler.
+	go func() {
+		response, err := s.finishRun(ctx, runID, testCaseIDs, opts)
+		if err != nil {
+			s.logger.Error("scheduled run failed", "schedule", id, "run", runID, "err", err)
+			return
+		}
+		s.logger.Info("scheduled run finished", "schedule", id, "run", runID, "results", len(response.Results))
+	}()
+	s.logger.Info("scheduled run started", "schedule", id, "run", runID)
+}
+
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `SELECT `+scheduleColumns+` FROM schedules ORDER BY created_at`)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		schedules = append(schedules, sc)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	scheduleID, err := uuid.Parse(ps.ByName("scheduleId"))
+	if err != nil {
+		writeError(w, validationError("Invalid schedule ID"))
+		return
+	}
+
+	sc, err := scanSchedule(s.db.QueryRowContext(r.Context(), `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, scheduleID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Schedule not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sc)
+}
+
+func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	sc := Schedule{Enabled: true}
+	if err := decodeBody(r, &sc); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	sc.ID = uuid.New()
+	sc.CronExpr = strings.TrimSpace(sc.CronExpr)
+	if err := sc.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	_, err = s.db.ExecContext(r.Context(), `INSERT INTO schedules (id, project_id, entity_id, cron_expr, label, enabled) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`,
+		sc.ID, sc.ProjectID, sc.EntityID, sc.CronExpr, sc.Label, sc.Enabled)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", s.path("/schedules/"+sc.ID.String()))
+	s.writeSchedule(r.Context(), w, sc, http.StatusCreated)
+}
+
+func (s *Server) updateSchedule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	scheduleID, err := uuid.Parse(ps.ByName("scheduleId"))
+	if err != nil {
+		writeError(w, validationError("Invalid schedule ID"))
+		return
+	}
+
+	sc, err := scanSchedule(s.db.QueryRowContext(r.Context(), `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, scheduleID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Schedule not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := decodeBody(r, &sc); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	sc.ID = scheduleID
+	sc.CronExpr = strings.TrimSpace(sc.CronExpr)
+	if err := sc.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	_, err = s.db.ExecContext(r.Context(), `UPDATE schedules SET project_id = $1, entity_id = $2, cron_expr = $3, label = NULLIF($4, ''), enabled = $5 WHERE id = $6`,
+		sc.ProjectID, sc.EntityID, sc.CronExpr, sc.Label, sc.Enabled, sc.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.writeSchedule(r.Context(), w, sc, http.StatusOK)
+}
+
+func (s *Server) writeSchedule(ctx context.Context, w http.ResponseWriter, sc Schedule, status int) {
+	if s.scheduler != nil {
+		if err := s.scheduleRun(ctx, sc); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	sc, err := scanSchedule(s.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, sc.ID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(sc)
+}
+
+func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	scheduleID, err := uuid.Parse(ps.ByName("scheduleId"))
+	if err != nil {
+		writeError(w, validationError("Invalid schedule ID"))
+		return
+	}
+
+	res, err := s.db.ExecContext(r.Context(), `DELETE FROM schedules WHERE id = $1`, scheduleID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Schedule not found"))
+		return
+	}
+
+	if s.scheduler != nil {
+		s.unscheduleRun(scheduleID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
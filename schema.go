@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+var schemaResources = map[string]any{
+	"project":   Project{},
+	"entity":    Entity{},
+	"test_case": TestCase{},
+}
+
+var (
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (s *Server) resourceSchema(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, _, err := s.authenticate(r); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	resource := ps.ByName("resource")
+	v, ok := schemaResources[resource]
+	if !ok {
+		writeError(w, notFoundError("Unknown resource"))
+		return
+	}
+
+	schema := structSchema(reflect.TypeOf(v))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = resource
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := typeSchema(f.Type)
+		if enum := f.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = prop
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := typeSchema(t.Elem())
+		if typ, ok := inner["type"].(string); ok {
+			inner["type"] = []string{typ, "null"}
+		}
+		return inner
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
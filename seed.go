@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	seedFlag  = flag.Bool("seed", false, "populate the database with demo data and exit")
+	forceFlag = flag.Bool("force", false, "allow -seed to run against a non-empty database")
+)
+
+func (s *Server) seedDatabase(ctx context.Context, force bool) error {
+	var nonEmpty bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users) OR EXISTS(SELECT 1 FROM projects)`).Scan(&nonEmpty)
+	if err != nil {
+		return err
+	}
+	if nonEmpty && !force {
+		return fmt.Errorf("database is not empty, rerun with -force to seed anyway")
+	}
+
+	tx, err := begin(ctx, s.db, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	users := []User{
+		{Email: "manager@example.com", Password: "manager", Role: managerRole},
+		{Email: "analyst@example.com", Password: "analyst", Role: testAnalystRole},
+		{Email: "tester@example.com", Password: "tester", Role: testerRole},
+	}
+	for _, u := range users {
+		hash, err := hashPassword(u.Password)
+		if err != nil {
+			return fmt.Errorf("seed user %s: %w", u.Email, err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO users (id, email, password, role)
+			SELECT $1, $2, $3, $4
+			WHERE NOT EXISTS (SELECT 1 FROM users WHERE email = $2)
+		`, uuid.New(), u.Email, hash, u.Role)
+		if err != nil {
+			return fmt.Errorf("seed user %s: %w", u.Email, err)
+		}
+	}
+
+	project := Project{ID: uuid.New(), Name: "Demo project", Description: "Sample project created by -seed"}
+	_, err = tx.Exec(`INSERT INTO projects (id, name, description) VALUES ($1, $2, $3)`,
+		project.ID, project.Name, project.Description)
+	if err != nil {
+		return fmt.Errorf("seed project: %w", err)
+	}
+
+	entity := Entity{
+		ID:          uuid.New(),
+		Name:        "Login form",
+		Description: "Sample entity created by -seed",
+		ProjectID:   project.ID,
+		JSONData:    json.RawMessage(`{"url": "/login"}`),
+	}
+	_, err = tx.Exec(`INSERT INTO entities (id, name, description, project_id, json_data) VALUES ($1, $2, $3, $4, $5)`,
+		entity.ID, entity.Name, entity.Description, entity.ProjectID, entity.JSONData)
+	if err != nil {
+		return fmt.Errorf("seed entity: %w", err)
+	}
+
+	testCases := []TestCase{
+		{Name: "Valid credentials", Description: "User logs in with a valid email and password", RequirementID: "REQ-1"},
+		{Name: "Wrong password", Description: "Login is rejected for a wrong password", RequirementID: "REQ-1"},
+		{Name: "Unknown email", Description: "Login is rejected for an unknown email", RequirementID: "REQ-1"},
+		{Name: "Empty form", Description: "Submitting an empty form shows validation errors", RequirementID: "REQ-2"},
+		{Name: "Password is masked", Description: "The password input hides typed characters", RequirementID: "REQ-3"},
+	}
+	for _, tc := range testCases {
+		_, err := tx.Exec(`
+			INSERT INTO test_cases (id, name, description, json_data, entity_id, project_id, requirement_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, uuid.New(), tc.Name, tc.Description, json.RawMessage(`{}`), entity.ID, project.ID, tc.RequirementID)
+		if err != nil {
+			return fmt.Errorf("seed test case %q: %w", tc.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
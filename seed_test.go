@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSeedDatabase(t *testing.T) {
+	t.Run("empty database", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("SELECT EXISTS(SELECT 1 FROM users)", []string{"exists"}, []driver.Value{false})
+		f.on("INSERT INTO", nil)
+		if err := s.seedDatabase(context.Background(), false); err != nil {
+			t.Fatalf("seedDatabase: %v", err)
+		}
+
+		roles := map[any]bool{}
+		for _, e := range f.executed("INSERT INTO users") {
+			roles[e.args[3]] = true
+			email, _ := e.args[1].(string)
+			password, _, _ := strings.Cut(email, "@")
+			if hash, _ := e.args[2].(string); !checkPassword(hash, password) {
+				t.Errorf("password for %s is not a bcrypt hash of %q: %q", email, password, hash)
+			}
+		}
+		for _, role := range []string{managerRole, testAnalystRole, testerRole} {
+			if !roles[role] {
+				t.Errorf("no %s user seeded", role)
+			}
+		}
+		for table, want := range map[string]int{"projects": 1, "entities": 1, "test_cases": 5} {
+			if n := len(f.executed("INSERT INTO " + table)); n != want {
+				t.Errorf("%d rows inserted into %s, want %d", n, table, want)
+			}
+		}
+	})
+
+	t.Run("non-empty database", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("SELECT EXISTS(SELECT 1 FROM users)", []string{"exists"}, []driver.Value{true})
+		if err := s.seedDatabase(context.Background(), false); err == nil {
+			t.Fatal("seedDatabase seeded a non-empty database without -force")
+		}
+		if n := len(f.executed("INSERT INTO")); n != 0 {
+			t.Errorf("%d inserts, want 0", n)
+		}
+	})
+
+	t.Run("non-empty database with force", func(t *testing.T) {
+		s, f := newTestServer(t)
+		f.on("SELECT EXISTS(SELECT 1 FROM users)", []string{"exists"}, []driver.Value{true})
+		f.on("INSERT INTO", nil)
+		if err := s.seedDatabase(context.Background(), true); err != nil {
+			t.Fatalf("seedDatabase: %v", err)
+		}
+		if n := len(f.executed("INSERT INTO test_cases")); n != 5 {
+			t.Errorf("%d test cases inserted, want 5", n)
+		}
+	})
+}
+
+// TestSeededCasesAreRunnable feeds the test cases -seed inserts back to
+// loadRunnableCases; their free-form requirement ids must not drop them
+// from a run.
+func TestSeededCasesAreRunnable(t *testing.T) {
+	s, f := newTestServer(t)
+	f.on("SELECT EXISTS(SELECT 1 FROM users)", []string{"exists"}, []driver.Value{false})
+	f.on("INSERT INTO", nil)
+	if err := s.seedDatabase(context.Background(), false); err != nil {
+		t.Fatalf("seedDatabase: %v", err)
+	}
+
+	inserted := f.executed("INSERT INTO test_cases")
+	if len(inserted) == 0 {
+		t.Fatal("seed inserted no test cases")
+	}
+	var ids []uuid.UUID
+	var rows [][]driver.Value
+	for _, e := range inserted {
+		// id, name, description, json_data, entity_id, project_id, requirement_id
+		id, name, jsonData, projectID, requirementID := e.args[0], e.args[1], e.args[3], e.args[5], e.args[6]
+		ids = append(ids, uuid.MustParse(id.(string)))
+		rows = append(rows, []driver.Value{id, name, projectID, requirementID, "{}", nil, jsonData})
+	}
+	f.on("FROM test_cases WHERE id = ANY($1)",
+		[]string{"id", "name", "project_id", "requirement_id", "depends_on", "timeout_ms", "json_data"}, rows...)
+
+	tx, err := begin(context.Background(), s.db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cases, err := s.loadRunnableCases(tx, ids, RunOptions{})
+	if err != nil {
+		t.Fatalf("loadRunnableCases: %v", err)
+	}
+	if len(cases) != len(inserted) {
+		t.Fatalf("loaded %d runnable cases, want %d", len(cases), len(inserted))
+	}
+	for i, c := range cases {
+		if want := inserted[i].args[6]; c.requirementID != want {
+			t.Errorf("case %q requirement = %q, want %q", c.name, c.requirementID, want)
+		}
+	}
+}
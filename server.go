@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type Server struct {
+	db           Database
+	cfg          Config
+	logger       *slog.Logger
+	router       *Router
+	status       statusFunc
+	requirements requirementLookup
+
+	requirementList  requirementLister
+	requirementCache *requirementCache
+
+	// replica serves the read-only list and report handlers. It is db
+	// unless DB_REPLICA_DSN is set.
+	replica Database
+
+	jwtKeys        *jwtKeyring
+	fieldCipher    *fieldCipher
+	scheduler      *scheduler
+	runs           *runRegistry
+	notifications  chan notifyJob
+	trustedProxies trustedProxies
+	runIsolation   sql.IsolationLevel
+	jwtTTL         time.Duration
+
+	maintenance atomic.Bool
+
+	handler http.Handler
+}
+
+func NewServer(db Database, cfg Config, logger *slog.Logger) (*Server, error) {
+	fc, err := newFieldCipher(cfg.JSONDataKeys)
+	if err != nil {
+		return nil, fmt.Errorf("JSONDATA_ENCRYPTION_KEYS: %w", err)
+	}
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	if cfg.BypassRole != "" && !isKnownRole(cfg.BypassRole) {
+		return nil, fmt.Errorf("BYPASS_ROLE: unknown role %q", cfg.BypassRole)
+	}
+	jwtKeys, err := newJWTKeyring(cfg.JWTSigningKeys)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KEYS: %w", err)
+	}
+	runIsolation, err := parseIsolationLevel(cfg.RunIsolation)
+	if err != nil {
+		return nil, fmt.Errorf("RUN_ISOLATION_LEVEL: %w", err)
+	}
+	jwtTTL, err := parseJWTTTL(cfg.JWTTTL)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_TTL: %w", err)
+	}
+
+	s := &Server{
+		db:      db,
+		replica: db,
+		cfg:     cfg,
+		logger:  logger,
+		router:  newRouter(cfg.BasePath, cfg.WriteTimeout),
+		status:  coinFlipStatus,
+
+		requirements:   acceptAllRequirements,
+		jwtKeys:        jwtKeys,
+		fieldCipher:    fc,
+		trustedProxies: proxies,
+		runIsolation:   runIsolation,
+		jwtTTL:         jwtTTL,
+		runs:           newRunRegistry(),
+		notifications:  make(chan notifyJob, notifyQueueSize),
+	}
+	go s.deliverNotifications()
+	s.requirementList = placeholderRequirements
+	if cfg.RequirementsCacheTTL > 0 {
+		s.requirementCache = newRequirementCache(s.requirementList, cfg.RequirementsCacheTTL)
+		s.requirementList = s.requirementCache.list
+	}
+	s.maintenance.Store(cfg.MaintenanceMode)
+	s.routes()
+	var h http.Handler = s.router
+	h = prettyJSON(h, cfg.PrettyJSON)
+	h = compressResponses(h, cfg.GzipMinSize)
+	h = requireJSONBody(h, cfg.BasePath)
+	h = s.rejectWritesInMaintenance(h)
+	s.handler = s.logRequests(h)
+	return s, nil
+}
+
+// path returns the canonical URL path of route p: BASE_PATH plus the
+// current API version.
+func (s *Server) path(p string) string {
+	return s.cfg.BasePath + currentAPIVersion + p
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.router.GET("/health", s.healthCheck)
+	s.router.GET("/ready", s.readinessCheck)
+
+	s.v1Routes(s.router.Group("/v1"))
+	// Unversioned aliases of v1, kept until existing clients move to /v1.
+	s.v1Routes(s.router)
+}
+
+// v1Routes registers the API. Runs, imports and bulk uploads get
+// LONG_REQUEST_TIMEOUT, everything else REQUEST_TIMEOUT.
+func (s *Server) v1Routes(rt *Router) {
+	long := rt.WithTimeout(s.cfg.LongRequestTimeout)
+	rt = rt.WithTimeout(s.cfg.RequestTimeout)
+
+	rt.PUT("/maintenance", s.setMaintenance)
+
+	rt.POST("/login", s.loginHandler)
+	rt.GET("/auth/validate", s.validateToken)
+	rt.GET("/password-policy", s.passwordPolicy)
+	rt.GET("/roles", s.listRoles)
+	rt.GET("/users", s.listUsers)
+	rt.POST("/users", s.createUser)
+	rt.PUT("/users/:userId/role", s.changeRole)
+	rt.POST("/users/:userId/deactivate", s.deactivateUser)
+	rt.POST("/users/:userId/reactivate", s.reactivateUser)
+	rt.GET("/schema/:resource", s.resourceSchema)
+
+	rt.GET("/projects", s.listProjects)
+	rt.POST("/projects", s.createProject)
+	long.POST("/projects/:projectId/run", s.runProject)
+	rt.GET("/projects/:projectId/runs", s.projectRuns)
+	rt.GET("/projects/:projectId/trends", s.failureTrends)
+	long.GET("/projects/:projectId/export", s.exportProject)
+	rt.PUT("/projects/:projectId/settings", s.updateProjectSettings)
+	rt.GET("/projects/:projectId/notification-routes", s.getNotificationRoutes)
+	rt.PUT("/projects/:projectId/notification-routes", s.updateNotificationRoutes)
+	rt.GET("/projects/:projectId/environments", s.listEnvironments)
+	rt.POST("/projects/:projectId/environments", s.createEnvironment)
+	rt.PUT("/projects/:projectId/environments/:environmentId", s.updateEnvironment)
+	rt.DELETE("/projects/:projectId/environments/:environmentId", s.deleteEnvironment)
+	rt.GET("/projects/:projectId/test-plans", s.listPlans)
+	rt.POST("/projects/:projectId/test-plans", s.createPlan)
+	rt.PUT("/projects/:projectId/test-plans/:planId", s.updatePlan)
+	rt.DELETE("/projects/:projectId/test-plans/:planId", s.deletePlan)
+	rt.GET("/projects/:projectId/test-suites", s.listSuites)
+	rt.POST("/projects/:projectId/test-suites", s.createSuite)
+	rt.PUT("/projects/:projectId/test-suites/:suiteId", s.updateSuite)
+	rt.DELETE("/projects/:projectId/test-suites/:suiteId", s.deleteSuite)
+	long.POST("/projects/:projectId/test-suites/:suiteId/run", s.runSuite)
+	long.POST("/projects/import", s.importProject)
+	rt.POST("/projects/batch-get", s.batchGetProjects)
+	rt.POST("/projects/bulk-archive", s.bulkArchive)
+	rt.GET("/runs/:runId/results", s.runResults)
+	rt.GET("/runs/:runId/junit", s.junitExport)
+	rt.GET("/runs/active", s.activeRuns)
+	rt.GET("/me/testcases", s.myTestCases)
+	rt.POST("/runs/:runId/cancel", s.cancelRun)
+	rt.POST("/runs/:runId/notify", s.replayNotifications)
+	long.POST("/runs/:runId/rerun", s.rerun)
+	rt.GET("/schedules", s.listSchedules)
+	rt.POST("/schedules", s.createSchedule)
+	rt.GET("/schedules/:scheduleId", s.getSchedule)
+	rt.PUT("/schedules/:scheduleId", s.updateSchedule)
+	rt.DELETE("/schedules/:scheduleId", s.deleteSchedule)
+	// get project - name, description, testcases
+	// delete project
+	// date of test end - add handle, default 2 weeks
+	rt.GET("/entities", s.listEntities)
+	rt.POST("/entities", s.addEntity)
+	long.POST("/entities/batch", s.batchUploadEntities)
+	long.POST("/entities/:entityId/run", s.runEntity)
+	rt.POST("/entities/:entityId/move", s.moveEntity)
+	rt.GET("/entities/:entityId/status", s.entityStatus)
+	rt.GET("/testcases", s.listTestCases)
+	rt.GET("/testcases/unlinked", s.listUnlinkedTestCases)
+	long.POST("/testcases/batch", s.batchUploadTestCases)
+	long.POST("/testcases/import/junit", s.importJUnit)
+	long.POST("/testcases/run", s.runTestCases)
+	rt.POST("/testcases/run/preview", s.runPreview)
+	rt.POST("/testcases/bulk-update", s.bulkUpdateTestCases)
+	rt.POST("/testcases/tags", s.applyTags)
+	rt.POST("/testcases/link-requirements", s.bulkLink)
+	rt.POST("/testcases/:testCaseId/assign", s.assignTestCase)
+	rt.GET("/testcases/:testCaseId/runs", s.testCaseRuns)
+	rt.GET("/testcases/:testCaseId/history", s.testCaseHistory)
+	rt.POST("/testcases/:testCaseId/revert/:version", s.revertTestCase)
+	rt.GET("/projects/:projectId/entities/:entityId/requirements", s.getRequirements)
+	rt.GET("/projects/:projectId/requirement-ids", s.distinctReqs)
+	rt.GET("/requirements/:requirementId/coverage", s.reqCoverage)
+	rt.POST("/requirements/remap", s.remapRequirement)
+	rt.DELETE("/requirements/cache", s.invalidateRequirementCache)
+	rt.POST("/projects/:projectId/members", s.addProjectMember)
+	rt.DELETE("/projects/:projectId/members/:userId", s.removeProjectMember)
+}
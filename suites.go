@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+// TestSuite is a named, ordered selection of a project's test cases that
+// can be run as a unit.
+type TestSuite struct {
+	ID          uuid.UUID   `json:"id"`
+	ProjectID   uuid.UUID   `json:"project_id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	TestCaseIDs []uuid.UUID `json:"test_case_ids"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+const maxSuiteNameLength = 200
+
+// validate checks the suite's fields and drops repeated test case IDs,
+// keeping the first occurrence.
+func (ts *TestSuite) validate() error {
+	ts.Name = strings.TrimSpace(ts.Name)
+	if ts.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(ts.Name) > maxSuiteNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxSuiteNameLength)
+	}
+	ids := make([]uuid.UUID, 0, len(ts.TestCaseIDs))
+	for _, id := range ts.TestCaseIDs {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	ts.TestCaseIDs = ids
+	return nil
+}
+
+const suiteColumns = `id, project_id, name, description, test_case_ids, created_at`
+
+func scanSuite(row interface{ Scan(...any) error }) (TestSuite, error) {
+	var ts TestSuite
+	if err := row.Scan(&ts.ID, &ts.ProjectID, &ts.Name, &ts.Description, pq.Array(&ts.TestCaseIDs), &ts.CreatedAt); err != nil {
+		return ts, err
+	}
+	if ts.TestCaseIDs == nil {
+		ts.TestCaseIDs = []uuid.UUID{}
+	}
+	return ts, nil
+}
+
+// checkSuiteCases writes a 400 and returns false unless every one of ids
+// is a test case of projectID.
+func (s *Server) checkSuiteCases(ctx context.Context, w http.ResponseWriter, projectID uuid.UUID, ids []uuid.UUID) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	found, err := s.queryIDs(ctx, `SELECT id FROM test_cases WHERE id = ANY($1) AND project_id = $2`, pq.Array(ids), projectID)
+	if err != nil {
+		writeError(w, err)
+		return false
+	}
+	for _, id := range ids {
+		if !slices.Contains(found, id) {
+			writeError(w, validationError(fmt.Sprintf("Test case %s is not in this project", id)))
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) listSuites(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, role)
+	if !ok {
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `SELECT `+suiteColumns+` FROM test_suites WHERE project_id = $1 ORDER BY name, id`, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	suites := []TestSuite{}
+	for rows.Next() {
+		ts, err := scanSuite(rows)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		suites = append(suites, ts)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suites)
+}
+
+func (s *Server) createSuite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+
+	var ts TestSuite
+	if err := decodeBody(r, &ts); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := ts.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if !s.checkSuiteCases(r.Context(), w, projectID, ts.TestCaseIDs) {
+		return
+	}
+
+	ts, err = scanSuite(s.db.QueryRowContext(r.Context(), `
+		INSERT INTO test_suites (id, project_id, name, description, test_case_ids)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+suiteColumns, uuid.New(), projectID, ts.Name, ts.Description, pq.Array(ts.TestCaseIDs)))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ts)
+}
+
+// updateSuite replaces the suite's name, description and test cases.
+func (s *Server) updateSuite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	suiteID, err := uuid.Parse(ps.ByName("suiteId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test suite ID"))
+		return
+	}
+
+	var ts TestSuite
+	if err := decodeBody(r, &ts); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if err := ts.validate(); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if !s.checkSuiteCases(r.Context(), w, projectID, ts.TestCaseIDs) {
+		return
+	}
+
+	ts, err = scanSuite(s.db.QueryRowContext(r.Context(), `
+		UPDATE test_suites SET name = $1, description = $2, test_case_ids = $3
+		WHERE id = $4 AND project_id = $5
+		RETURNING `+suiteColumns, ts.Name, ts.Description, pq.Array(ts.TestCaseIDs), suiteID, projectID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test suite not found"))
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ts)
+}
+
+func (s *Server) deleteSuite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, managerRole)
+	if !ok {
+		return
+	}
+	suiteID, err := uuid.Parse(ps.ByName("suiteId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test suite ID"))
+		return
+	}
+
+	res, err := s.db.ExecContext(r.Context(), `DELETE FROM test_suites WHERE id = $1 AND project_id = $2`, suiteID, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, notFoundError("Test suite not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSuite runs the suite's test cases in its order. Cases deleted since
+// they were added are left out.
+func (s *Server) runSuite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	projectID, ok := s.projectParam(w, r, ps, userID, testerRole)
+	if !ok {
+		return
+	}
+	suiteID, err := uuid.Parse(ps.ByName("suiteId"))
+	if err != nil {
+		writeError(w, validationError("Invalid test suite ID"))
+		return
+	}
+
+	ts, err := scanSuite(s.db.QueryRowContext(r.Context(), `SELECT `+suiteColumns+` FROM test_suites WHERE id = $1 AND project_id = $2`, suiteID, projectID))
+	if err == sql.ErrNoRows {
+		writeError(w, notFoundError("Test suite not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	opts, err := decodeRunOptions(r)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if opts.Label == "" {
+		opts.Label = "suite:" + ts.ID.String()
+	}
+
+	switch {
+	case len(ts.TestCaseIDs) == 0:
+		writeError(w, validationError("Test suite has no test cases"))
+		return
+	case len(ts.TestCaseIDs) > s.cfg.MaxRunCases:
+		http.Error(w, fmt.Sprintf("Test suite has %d test cases, which exceeds the maximum of %d per run",
+			len(ts.TestCaseIDs), s.cfg.MaxRunCases), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	s.respondRun(w, r, ts.TestCaseIDs, opts)
+}
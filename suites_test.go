@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateSuite(t *testing.T) {
+	projectID, inProject, elsewhere := uuid.New(), uuid.New(), uuid.New()
+	path := "/v1/projects/" + projectID.String() + "/test-suites"
+
+	t.Run("success", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+		f.on("SELECT id FROM test_cases WHERE id = ANY($1) AND project_id = $2", []string{"id"},
+			[]driver.Value{inProject.String()})
+		f.on("INSERT INTO test_suites", []string{"id", "project_id", "name", "description", "test_case_ids", "created_at"},
+			[]driver.Value{uuid.NewString(), projectID.String(), "Smoke", "", "{" + inProject.String() + "}", time.Now()})
+
+		rec := serve(s, http.MethodPost, path, token,
+			`{"name": " Smoke ", "test_case_ids": ["`+inProject.String()+`", "`+inProject.String()+`"]}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var ts TestSuite
+		if err := json.Unmarshal(rec.Body.Bytes(), &ts); err != nil {
+			t.Fatal(err)
+		}
+		if len(ts.TestCaseIDs) != 1 || ts.TestCaseIDs[0] != inProject {
+			t.Errorf("test_case_ids = %v, want [%s]", ts.TestCaseIDs, inProject)
+		}
+	})
+
+	t.Run("case from another project", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+		f.on("SELECT id FROM test_cases WHERE id = ANY($1) AND project_id = $2", []string{"id"},
+			[]driver.Value{inProject.String()})
+
+		rec := serve(s, http.MethodPost, path, token,
+			`{"name": "Smoke", "test_case_ids": ["`+inProject.String()+`", "`+elsewhere.String()+`"]}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, managerRole)
+
+		rec := serve(s, http.MethodPost, path, token, `{"name": "  "}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("wrong role", func(t *testing.T) {
+		s, f := newTestServer(t)
+		token := tokenFor(t, s, f, testerRole)
+
+		rec := serve(s, http.MethodPost, path, token, `{"name": "Smoke"}`)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestSuiteValidateDropsRepeatedCases(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	ts := TestSuite{Name: "Smoke", TestCaseIDs: []uuid.UUID{a, b, a}}
+	if err := ts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.TestCaseIDs) != 2 || ts.TestCaseIDs[0] != a || ts.TestCaseIDs[1] != b {
+		t.Errorf("test_case_ids = %v, want [%s %s]", ts.TestCaseIDs, a, b)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+const maxTagLength = 50
+
+// normalizeTags trims tags, drops duplicates and sorts them so stored tag
+// arrays compare equal regardless of input order. nil becomes an empty
+// list.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+			return nil, fmt.Errorf("tags must not be empty")
+		case len(tag) > maxTagLength:
+			return nil, fmt.Errorf("tag %q must be at most %d characters", tag, maxTagLength)
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+type TagUpdate struct {
+	IDs    []uuid.UUID `json:"ids"`
+	Add    []string    `json:"add"`
+	Remove []string    `json:"remove"`
+}
+
+func (s *Server) applyTags(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := s.authenticateAndCheckRole(r, testAnalystRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var req TagUpdate
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeError(w, validationError("No test case IDs provided"))
+		return
+	}
+	if len(req.IDs) > s.cfg.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d test cases exceeds the maximum of %d", len(req.IDs), s.cfg.MaxBatchSize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	add, err := normalizeTags(req.Add)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	remove, err := normalizeTags(req.Remove)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		writeError(w, validationError("No tags to add or remove"))
+		return
+	}
+
+	updated := []uuid.UUID{}
+	err = withTx(r.Context(), s.db, func(tx *Tx) error {
+		before, err := snapshotTestCases(tx, req.IDs)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(`
+			UPDATE test_cases SET tags = ARRAY(
+				SELECT DISTINCT t FROM unnest(tags || $1::text[]) t
+				WHERE t <> ALL($2::text[])
+				ORDER BY t)
+			WHERE id = ANY($3)
+			RETURNING id`, pq.Array(add), pq.Array(remove), pq.Array(req.IDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		updated = updated[:0]
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			updated = append(updated, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return recordTestCaseRevisions(tx, before, userID, nil)
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]uuid.UUID{"updated": updated})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// trendIntervals maps the accepted ?interval= values to an approximate
+// length, used only to bound the number of buckets.
+var trendIntervals = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+var trendIntervalNames = []string{"hour", "day", "week", "month"}
+
+const (
+	defaultTrendInterval = "day"
+	defaultTrendRange    = 30 * 24 * time.Hour
+	maxTrendBuckets      = 1000
+)
+
+type TrendBucket struct {
+	Start time.Time `json:"start"`
+	RunSummary
+}
+
+type TrendsResponse struct {
+	Interval string        `json:"interval"`
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Buckets  []TrendBucket `json:"buckets"`
+}
+
+// failureTrends counts a project's run results per interval between ?from
+// and ?to (RFC 3339, default the last 30 days). Buckets without results are
+// included with zero counts so the series has no gaps.
+func (s *Server) failureTrends(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID, role, err := s.authenticate(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	projectID, err := uuid.Parse(ps.ByName("projectId"))
+	if err != nil {
+		writeError(w, validationError("Invalid project ID"))
+		return
+	}
+
+	allowed, err := s.canAccessProject(r.Context(), userID, role, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !allowed {
+		writeError(w, notFoundError("Project not found"))
+		return
+	}
+
+	q := r.URL.Query()
+	resp := TrendsResponse{Interval: q.Get("interval"), To: time.Now().UTC(), Buckets: []TrendBucket{}}
+	if resp.Interval == "" {
+		resp.Interval = defaultTrendInterval
+	}
+	length, ok := trendIntervals[resp.Interval]
+	if !ok {
+		writeError(w, validationError("interval must be one of "+strings.Join(trendIntervalNames, ", ")))
+		return
+	}
+
+	for _, bound := range []struct {
+		param string
+		t     *time.Time
+	}{{"to", &resp.To}, {"from", &resp.From}} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, validationError(fmt.Sprintf("Invalid %s: expected RFC 3339 timestamp", bound.param)))
+			return
+		}
+		*bound.t = t
+	}
+	if resp.From.IsZero() {
+		resp.From = resp.To.Add(-defaultTrendRange)
+	}
+	if !resp.From.Before(resp.To) {
+		writeError(w, validationError("from must be before to"))
+		return
+	}
+	if resp.To.Sub(resp.From)/length > maxTrendBuckets {
+		writeError(w, validationError(fmt.Sprintf("Range spans more than %d %s buckets", maxTrendBuckets, resp.Interval)))
+		return
+	}
+
+	rows, err := s.replica.QueryContext(r.Context(), `
+		SELECT b.start,
+			COUNT(rr.status),
+			COUNT(*) FILTER (WHERE rr.status = 'passed'),
+			COUNT(*) FILTER (WHERE rr.status = 'failed')
+		FROM generate_series(date_trunc($1, $2::timestamptz), $3::timestamptz, ('1 ' || $1)::interval) AS b(start)
+		LEFT JOIN test_run_results rr
+			ON date_trunc($1, rr.run_time) = b.start
+			AND rr.run_time >= $2 AND rr.run_time <= $3
+			AND rr.test_case_id IN (SELECT id FROM test_cases WHERE project_id = $4)
+		GROUP BY b.start
+		ORDER BY b.start`, resp.Interval, resp.From, resp.To, projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b TrendBucket
+		if err := rows.Scan(&b.Start, &b.Total, &b.Passed, &b.Failed); err != nil {
+			writeError(w, err)
+			return
+		}
+		if b.Total > 0 {
+			b.PassRate = float64(b.Passed) / float64(b.Total)
+		}
+		resp.Buckets = append(resp.Buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
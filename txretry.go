@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 50 * time.Millisecond
+)
+
+// withTx runs fn in a transaction and commits it. Serialization failures,
+// deadlocks and dropped connections retry the whole transaction with
+// exponential backoff, so fn may run more than once and must rebuild any
+// state it reports from scratch on each call.
+func withTx(ctx context.Context, db Database, fn func(tx *Tx) error) error {
+	return withTxOptions(ctx, db, nil, fn)
+}
+
+func withTxOptions(ctx context.Context, db Database, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	backoff := txRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || attempt == maxTxAttempts || !isTransientDBError(err) {
+			return err
+		}
+		select {
+		case <-time.After(backoff + rand.N(backoff)):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func runTx(ctx context.Context, db Database, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	tx, err := begin(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isTransientDBError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "serialization_failure", "deadlock_detected":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseIsolationLevel maps the Postgres names of the isolation levels to
+// their database/sql constants; an empty name keeps the server default.
+func parseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " ")) {
+	case "":
+		return sql.LevelDefault, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return 0, fmt.Errorf("unknown isolation level %q", name)
+}
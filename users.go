@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// normalizeEmail validates a bare address (no display name) and lowercases
+// it, so addresses differing only in case refer to the same user.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", fmt.Errorf("invalid email address")
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// hashPassword returns the bcrypt hash stored in users.password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", validationError("password must be at most 72 bytes")
+	}
+	return string(hash), err
+}
+
+// dummyPasswordHash is compared against when a login names an unknown email,
+// so the response takes as long as for a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// checkPassword reports whether password matches the stored bcrypt hash.
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var user User
+	if err := decodeBody(r, &user); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	user.Email, err = normalizeEmail(user.Email)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+	if user.Password == "" {
+		writeError(w, validationError("password is required"))
+		return
+	}
+	if err := s.cfg.PasswordPolicy.check(user.Password); err != nil {
+		writeError(w, err)
+		return
+	}
+	if !isKnownRole(user.Role) {
+		writeError(w, validationError("Unknown role"))
+		return
+	}
+
+	hash, err := hashPassword(user.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user.ID = uuid.New()
+	_, err = s.db.ExecContext(r.Context(), `INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)`,
+		user.ID, user.Email, hash, user.Role)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	user.Password = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+type UserSummary struct {
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	IsActive bool      `json:"is_active"`
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	_, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	var where whereClause
+	if role := r.URL.Query().Get("role"); role != "" {
+		if !isKnownRole(role) {
+			writeError(w, validationError("Unknown role"))
+			return
+		}
+		where.add("role = " + where.arg(role))
+	}
+
+	limit, offset, err := parsePagination(r, s.cfg.UsersPage)
+	if err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	page := Page[UserSummary]{Items: []UserSummary{}, Limit: limit, Offset: offset}
+	if err := s.db.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM users`+where.String(), where.args...).Scan(&page.Total); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	args := append(where.args, limit, offset)
+	rows, err := s.db.QueryContext(r.Context(), `SELECT id, email, role, is_active FROM users`+where.String()+
+		fmt.Sprintf(` ORDER BY email LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u UserSummary
+		if err := rows.Scan(&u.ID, &u.Email, &u.Role, &u.IsActive); err != nil {
+			writeError(w, err)
+			return
+		}
+		page.Items = append(page.Items, u)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	page.setLinks(r)
+	setTotalCount(w, page.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+// changeRole updates a user's role and bumps their token version, so tokens
+// issued under the old role stop working. The last manager cannot be
+// demoted.
+func (s *Server) changeRole(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	actorID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	userID, err := uuid.Parse(ps.ByName("userId"))
+	if err != nil {
+		writeError(w, validationError("Invalid user ID"))
+		return
+	}
+
+	var req RoleChange
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, validationError(err.Error()))
+		return
+	}
+
+	user, err := s.updateUserRole(r.Context(), actorID, userID, req.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (s *Server) updateUserRole(ctx context.Context, actorID, userID uuid.UUID, role string) (UserSummary, error) {
+	if !isKnownRole(role) {
+		return UserSummary{}, validationError("Unknown role")
+	}
+
+	tx, err := begin(ctx, s.db, nil)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	defer tx.Rollback()
+
+	user, err := lockUser(tx, userID)
+	if err != nil {
+		return user, err
+	}
+
+	if user.Role == managerRole && role != managerRole && user.IsActive {
+		if err := ensureOtherManager(tx, "Cannot demote the last manager"); err != nil {
+			return user, err
+		}
+	}
+
+	if oldRole := user.Role; oldRole != role {
+		_, err = tx.Exec(`UPDATE users SET role = $1, token_version = token_version + 1 WHERE id = $2`, role, userID)
+		if err != nil {
+			return user, err
+		}
+		err = recordAudit(ctx, tx, actorID, "user.role_changed", "user", userID.String(),
+			map[string]string{"from": oldRole, "to": role})
+		if err != nil {
+			return user, err
+		}
+		user.Role = role
+	}
+
+	return user, tx.Commit()
+}
+
+// lockUser loads a user and locks the row for the rest of tx.
+func lockUser(tx *Tx, userID uuid.UUID) (UserSummary, error) {
+	var user UserSummary
+	err := tx.QueryRow(`SELECT id, email, role, is_active FROM users WHERE id = $1 FOR UPDATE`, userID).
+		Scan(&user.ID, &user.Email, &user.Role, &user.IsActive)
+	if err == sql.ErrNoRows {
+		return user, notFoundError("User not found")
+	}
+	return user, err
+}
+
+// ensureOtherManager locks every active manager row for the rest of tx and
+// fails with a conflict unless there are at least two, so concurrent
+// demotions cannot both see a second manager.
+func ensureOtherManager(tx *Tx, msg string) error {
+	rows, err := tx.Query(`SELECT id FROM users WHERE role = $1 AND is_active FOR UPDATE`, managerRole)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if n <= 1 {
+		return conflictError(msg)
+	}
+	return nil
+}
+
+func (s *Server) deactivateUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.setUserActive(w, r, ps, false)
+}
+
+func (s *Server) reactivateUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.setUserActive(w, r, ps, true)
+}
+
+func (s *Server) setUserActive(w http.ResponseWriter, r *http.Request, ps httprouter.Params, active bool) {
+	actorID, err := s.authenticateAndCheckRole(r, managerRole)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	userID, err := uuid.Parse(ps.ByName("userId"))
+	if err != nil {
+		writeError(w, validationError("Invalid user ID"))
+		return
+	}
+
+	user, err := s.updateUserActive(r.Context(), actorID, userID, active)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// updateUserActive flips users.is_active. Deactivated users keep their rows,
+// so revisions and audit entries that reference them stay intact.
+func (s *Server) updateUserActive(ctx context.Context, actorID, userID uuid.UUID, active bool) (UserSummary, error) {
+	tx, err := begin(ctx, s.db, nil)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	defer tx.Rollback()
+
+	user, err := lockUser(tx, userID)
+	if err != nil || user.IsActive == active {
+		return user, err
+	}
+
+	if !active && user.Role == managerRole {
+		if err := ensureOtherManager(tx, "Cannot deactivate the last manager"); err != nil {
+			return user, err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET is_active = $1 WHERE id = $2`, active, userID); err != nil {
+		return user, err
+	}
+	action := "user.deactivated"
+	if active {
+		action = "user.reactivated"
+	}
+	if err := recordAudit(ctx, tx, actorID, action, "user", userID.String(), nil); err != nil {
+		return user, err
+	}
+	user.IsActive = active
+
+	return user, tx.Commit()
+}